@@ -20,17 +20,43 @@
 package chain
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 
+	ethcom "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/common/config"
+	"github.com/ontio/ontology/common/constants"
 	"github.com/ontio/ontology/core/ledger"
+	"github.com/ontio/ontology/core/payload"
+	scom "github.com/ontio/ontology/core/store/common"
+	ctypes "github.com/ontio/ontology/core/types"
+	"github.com/ontio/ontology/core/utils"
 	hcommon "github.com/ontio/ontology/http/base/common"
+	"github.com/ontio/ontology/smartcontract/event"
+	"github.com/ontio/ontology/smartcontract/service/native/governance"
+	"github.com/ontio/ontology/smartcontract/service/native/ont"
+	nutils "github.com/ontio/ontology/smartcontract/service/native/utils"
 	"github.com/ontio/ontology/smartcontract/states"
 )
 
+// ErrNotDeployed is returned when a contract hasn't been deployed as of the
+// current height of the ledger.
+var ErrNotDeployed = errors.New("chain: contract not deployed")
+
+// OEP4 represents the token metadata reported by an OEP4 contract.
+type OEP4 struct {
+	Decimals int32
+	Symbol   string
+}
+
 // BalanceOf calls a contract's balanceOf method for the given account.
 func BalanceOf(acct common.Address, contract common.Address) (*big.Int, error) {
 	r, err := Exec(contract, "balanceOf", []interface{}{acct})
@@ -51,6 +77,77 @@ func BalanceOf(acct common.Address, contract common.Address) (*big.Int, error) {
 	return common.BigIntFromNeoBytes(val), nil
 }
 
+// DecodeBoolResult decodes the boolean returned by a pre-executed NeoVM or
+// WASM contract method.
+func DecodeBoolResult(r *states.PreExecResult, method string, wasm bool) (bool, error) {
+	raw, ok := r.Result.(string)
+	if !ok {
+		return false, fmt.Errorf(
+			`chain: unexpected %q response type: %s`, method, reflect.TypeOf(r.Result),
+		)
+	}
+	val, err := hex.DecodeString(raw)
+	if err != nil {
+		return false, err
+	}
+	if wasm {
+		if len(val) != 1 {
+			return false, fmt.Errorf(`chain: unexpected %q response length: %d`, method, len(val))
+		}
+		switch val[0] {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		}
+		return false, fmt.Errorf(`chain: unexpected %q response value: %d`, method, val[0])
+	}
+	// NOTE(tav): NeoVM encodes false as "00", but contracts may also return an
+	// empty byte array, so any value without a non-zero byte is treated as
+	// false.
+	for _, b := range val {
+		if b != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ERC20BalanceOf calls an ERC-20 contract's balanceOf method on the EVM for the
+// given account.
+func ERC20BalanceOf(acct common.Address, contract common.Address) (*big.Int, error) {
+	data := append(ethcrypto.Keccak256([]byte("balanceOf(address)"))[:4], ethcom.LeftPadBytes(acct[:], 32)...)
+	out, err := EvmExec(contract, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf(
+			`chain: unexpected "balanceOf" response length: %d`, len(out),
+		)
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// EvmExec executes a call to a contract on the EVM with the given input data,
+// and returns its output.
+func EvmExec(contract common.Address, data []byte) ([]byte, error) {
+	// NOTE(tav): We use the same gas cap as the node does for eth_call.
+	to := ethcom.Address(contract)
+	msg := ethtypes.NewMessage(
+		ethcom.Address{}, &to, 0, big.NewInt(0), config.DEFAULT_ETH_TX_MAX_GAS_LIMIT,
+		big.NewInt(0), data, false,
+	)
+	res, err := ledger.DefLedger.PreExecuteEip155Tx(msg)
+	if err != nil {
+		return nil, err
+	}
+	if res.Failed() {
+		return nil, fmt.Errorf("chain: EVM call failed: %s", res.Err)
+	}
+	return res.Return(), nil
+}
+
 // Exec executes a method on a contract with the given parameters.
 func Exec(contract common.Address, method string, params []interface{}) (*states.PreExecResult, error) {
 	mut, err := hcommon.NewNeovmInvokeTransaction(0, 0, contract, []interface{}{method, params})
@@ -96,3 +193,300 @@ func NativeExec(contract common.Address, method string, params []interface{}) (*
 	}
 	return ledger.DefLedger.PreExecuteContract(txn)
 }
+
+// OEP4Name calls an OEP4 contract's name method.
+func OEP4Name(contract common.Address, wasm bool) (string, error) {
+	exec := Exec
+	if wasm {
+		exec = WasmExec
+	}
+	r, err := exec(contract, "name", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf(`chain: failed to call "name": %s`, err)
+	}
+	return decodeStringResult(r, "name", wasm)
+}
+
+// PreExecute executes the given transaction against the current state of the
+// ledger without committing it. The transaction is treated as if it had been
+// signed by the given signers, so that any witness checks will pass.
+func PreExecute(txn *ctypes.Transaction, signers []common.Address) (*states.PreExecResult, error) {
+	txn.SignedAddr = signers
+	res, err := ledger.DefLedger.PreExecuteContract(txn)
+	if err != nil {
+		return nil, err
+	}
+	if res.State == event.CONTRACT_STATE_FAIL {
+		return nil, fmt.Errorf("chain: transaction execution failed")
+	}
+	return res, nil
+}
+
+// StakedBalance returns the ONT that an account has staked with the given
+// consensus node, in units of 10^-9 ONT. This is the sum of the account's
+// authorized positions that haven't been withdrawn yet, along with the node's
+// initial position if the account owns the node.
+func StakedBalance(acct common.Address, peer []byte) (*big.Int, error) {
+	gov := nutils.GovernanceContractAddress
+	key := append(append([]byte{}, governance.AUTHORIZE_INFO_POOL...), peer...)
+	raw, err := ledger.DefLedger.GetStorageItem(gov, append(key, acct[:]...))
+	if err != nil && err != scom.ErrNotFound {
+		return nil, err
+	}
+	total := &big.Int{}
+	if len(raw) > 0 {
+		info := &governance.AuthorizeInfo{}
+		if err := info.Deserialization(common.NewZeroCopySource(raw)); err != nil {
+			return nil, fmt.Errorf("chain: unable to decode authorize info: %s", err)
+		}
+		for _, pos := range []uint64{
+			info.ConsensusPos, info.CandidatePos, info.NewPos,
+			info.WithdrawConsensusPos, info.WithdrawCandidatePos, info.WithdrawUnfreezePos,
+		} {
+			total.Add(total, new(big.Int).SetUint64(pos))
+		}
+	}
+	raw, err = ledger.DefLedger.GetStorageItem(gov, []byte(governance.GOVERNANCE_VIEW))
+	if err != nil {
+		return nil, err
+	}
+	view := &governance.GovernanceView{}
+	if err := view.Deserialize(bytes.NewBuffer(raw)); err != nil {
+		return nil, fmt.Errorf("chain: unable to decode governance view: %s", err)
+	}
+	key = append([]byte(governance.PEER_POOL), governance.GetUint32Bytes(view.View)...)
+	raw, err = ledger.DefLedger.GetStorageItem(gov, key)
+	if err != nil {
+		return nil, err
+	}
+	pool := &governance.PeerPoolMap{
+		PeerPoolMap: map[string]*governance.PeerPoolItem{},
+	}
+	if err := pool.Deserialization(common.NewZeroCopySource(raw)); err != nil {
+		return nil, fmt.Errorf("chain: unable to decode peer pool: %s", err)
+	}
+	if item, ok := pool.PeerPoolMap[hex.EncodeToString(peer)]; ok && item.Address == acct {
+		total.Add(total, new(big.Int).SetUint64(item.InitPos))
+	}
+	return total.Mul(total, big.NewInt(constants.GWei)), nil
+}
+
+// UnboundONG returns the ONG that the ONT contract has granted to the given
+// account and which can be claimed, along with the unbound ONG that has
+// accrued since the last grant. The accrued amount is only granted on the
+// account's next ONT transfer. Both values are in units of 10^-18 ONG.
+func UnboundONG(acct common.Address) (claimable *big.Int, accrued *big.Int, err error) {
+	allowance := struct {
+		From common.Address
+		To   common.Address
+	}{nutils.OntContractAddress, acct}
+	r, err := NativeExec(nutils.OngContractAddress, "allowanceV2", []interface{}{&allowance})
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, ok := r.Result.(string)
+	if !ok {
+		return nil, nil, fmt.Errorf(
+			`chain: unexpected "allowanceV2" response type: %s`,
+			reflect.TypeOf(r),
+		)
+	}
+	val, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	claimable = common.BigIntFromNeoBytes(val)
+	key := append([]byte(ont.UNBOUND_TIME_OFFSET_KEY), acct[:]...)
+	offset, err := ledger.DefLedger.GetStorageItem(nutils.OntContractAddress, key)
+	if err != nil && err != scom.ErrNotFound {
+		return nil, nil, err
+	}
+	start := uint32(0)
+	if len(offset) > 0 {
+		start, err = common.NewZeroCopySource(offset).ReadUint32()
+		if err != nil {
+			return nil, nil, fmt.Errorf("chain: unable to decode unbound time offset: %s", err)
+		}
+	}
+	ontBalance, err := NativeBalanceOf(acct, nutils.OntContractAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	height := ledger.DefLedger.GetCurrentBlockHeight()
+	header, err := ledger.DefLedger.GetHeaderByHeight(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.Timestamp <= constants.GENESIS_BLOCK_TIMESTAMP {
+		return claimable, big.NewInt(0), nil
+	}
+	// NOTE(tav): Unbound ONG accrues on whole units of ONT, and is
+	// calculated in units of 10^-9 ONG.
+	units := ontBalance.Div(ontBalance, big.NewInt(1e9)).Uint64()
+	amount := nutils.CalcUnbindOng(units, start, header.Timestamp-constants.GENESIS_BLOCK_TIMESTAMP)
+	accrued = new(big.Int).SetUint64(amount)
+	return claimable, accrued.Mul(accrued, big.NewInt(1e9)), nil
+}
+
+// VerifyOEP4 checks that an OEP4 contract exposes the balanceOf, decimals,
+// symbol, and transfer methods with the expected signatures, by pre-executing
+// them, and returns the token metadata reported by the contract. The optional
+// name method isn't checked, as it's only needed when discovering tokens.
+func VerifyOEP4(contract common.Address, wasm bool) (*OEP4, error) {
+	deploy, err := ledger.DefLedger.GetContractState(contract)
+	if err == scom.ErrNotFound || (err == nil && deploy == nil) {
+		return nil, ErrNotDeployed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isWasm := deploy.VmType() == payload.WASMVM_TYPE; isWasm != wasm {
+		return nil, fmt.Errorf("chain: mismatching contract vm type: wasm is %v", isWasm)
+	}
+	balanceOf, exec := BalanceOf, Exec
+	if wasm {
+		balanceOf, exec = WasmBalanceOf, WasmExec
+	}
+	if _, err := balanceOf(nilAddr, contract); err != nil {
+		return nil, fmt.Errorf(`chain: failed to call "balanceOf": %s`, err)
+	}
+	r, err := exec(contract, "decimals", []interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf(`chain: failed to call "decimals": %s`, err)
+	}
+	decimals, err := decodeIntResult(r, "decimals", wasm)
+	if err != nil {
+		return nil, err
+	}
+	if !decimals.IsInt64() || decimals.Int64() < 0 || decimals.Int64() > math.MaxInt32 {
+		return nil, fmt.Errorf(`chain: invalid "decimals" value: %s`, decimals)
+	}
+	r, err = exec(contract, "symbol", []interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf(`chain: failed to call "symbol": %s`, err)
+	}
+	symbol, err := decodeStringResult(r, "symbol", wasm)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE(tav): A zero value transfer to itself from an account without any
+	// balance is enough to check that the method takes the expected params,
+	// as an invalid signature causes the execution to fail.
+	var mut *ctypes.MutableTransaction
+	if wasm {
+		mut, err = utils.NewWasmVMInvokeTransaction(0, 0, contract, []interface{}{
+			"transfer", nilAddr, nilAddr, big.NewInt(0),
+		})
+	} else {
+		mut, err = hcommon.NewNeovmInvokeTransaction(0, 0, contract, []interface{}{
+			"transfer", []interface{}{nilAddr, nilAddr, big.NewInt(0)},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	txn, err := mut.IntoImmutable()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := PreExecute(txn, []common.Address{nilAddr}); err != nil {
+		return nil, fmt.Errorf(`chain: failed to call "transfer": %s`, err)
+	}
+	return &OEP4{
+		Decimals: int32(decimals.Int64()),
+		Symbol:   symbol,
+	}, nil
+}
+
+// WasmBalanceOf calls a WASM contract's balanceOf method for the given account.
+func WasmBalanceOf(acct common.Address, contract common.Address) (*big.Int, error) {
+	r, err := WasmExec(contract, "balanceOf", []interface{}{acct})
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := r.Result.(string)
+	if !ok {
+		return nil, fmt.Errorf(
+			`chain: unexpected "balanceOf" response type: %s`,
+			reflect.TypeOf(r),
+		)
+	}
+	val, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) != common.I128_SIZE {
+		return nil, fmt.Errorf(
+			`chain: unexpected "balanceOf" response length: %d`, len(val),
+		)
+	}
+	balance := common.U128{}
+	copy(balance[:], val)
+	return balance.ToBigInt(), nil
+}
+
+// WasmExec executes a method on a WASM contract with the given parameters.
+func WasmExec(contract common.Address, method string, params []interface{}) (*states.PreExecResult, error) {
+	mut, err := utils.NewWasmVMInvokeTransaction(0, 0, contract, append([]interface{}{method}, params...))
+	if err != nil {
+		return nil, err
+	}
+	txn, err := mut.IntoImmutable()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.DefLedger.PreExecuteContract(txn)
+}
+
+func decodeIntResult(r *states.PreExecResult, method string, wasm bool) (*big.Int, error) {
+	raw, ok := r.Result.(string)
+	if !ok {
+		return nil, fmt.Errorf(
+			`chain: unexpected %q response type: %s`, method, reflect.TypeOf(r.Result),
+		)
+	}
+	val, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !wasm {
+		return common.BigIntFromNeoBytes(val), nil
+	}
+	// NOTE(tav): WASM contracts return integers in little-endian order, with
+	// the size depending on the type used by the contract.
+	switch len(val) {
+	case 1, 2, 4, 8, common.I128_SIZE:
+	default:
+		return nil, fmt.Errorf(`chain: unexpected %q response length: %d`, method, len(val))
+	}
+	buf := make([]byte, len(val))
+	for i, b := range val {
+		buf[len(val)-1-i] = b
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+func decodeStringResult(r *states.PreExecResult, method string, wasm bool) (string, error) {
+	raw, ok := r.Result.(string)
+	if !ok {
+		return "", fmt.Errorf(
+			`chain: unexpected %q response type: %s`, method, reflect.TypeOf(r.Result),
+		)
+	}
+	val, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+	if wasm {
+		src := common.NewZeroCopySource(val)
+		s, err := src.ReadString()
+		if err != nil {
+			return "", fmt.Errorf(`chain: unable to decode %q response: %s`, method, err)
+		}
+		if src.Len() != 0 {
+			return "", fmt.Errorf(`chain: unexpected trailing data in %q response`, method)
+		}
+		return s, nil
+	}
+	return string(val), nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package chain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/smartcontract/states"
+)
+
+func TestDecodeResults(t *testing.T) {
+	for _, tc := range []struct {
+		result string
+		wasm   bool
+		want   int64
+	}{
+		{"09", false, 9},
+		{"12", false, 18},
+		{"", false, 0},
+		{"09", true, 9},
+		{"12000000000000000000000000000000", true, 18},
+	} {
+		val, err := decodeIntResult(&states.PreExecResult{Result: tc.result}, "decimals", tc.wasm)
+		if err != nil {
+			t.Fatalf("Failed to decode %q: %s", tc.result, err)
+		}
+		if val.Int64() != tc.want {
+			t.Fatalf("Unexpected value for %q: got %s, want %d", tc.result, val, tc.want)
+		}
+	}
+	if _, err := decodeIntResult(&states.PreExecResult{Result: "090000"}, "decimals", true); err == nil {
+		t.Fatalf("Expected an error for an unexpected WASM integer size")
+	}
+	sym, err := decodeStringResult(&states.PreExecResult{
+		Result: hex.EncodeToString([]byte("WING")),
+	}, "symbol", false)
+	if err != nil || sym != "WING" {
+		t.Fatalf("Unexpected NeoVM symbol: %q (%v)", sym, err)
+	}
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteString("WING")
+	sym, err = decodeStringResult(&states.PreExecResult{
+		Result: hex.EncodeToString(sink.Bytes()),
+	}, "symbol", true)
+	if err != nil || sym != "WING" {
+		t.Fatalf("Unexpected WASM symbol: %q (%v)", sym, err)
+	}
+	for _, tc := range []struct {
+		result string
+		wasm   bool
+		want   bool
+	}{
+		{"", false, false},
+		{"00", false, false},
+		{"0000", false, false},
+		{"01", false, true},
+		{"00", true, false},
+		{"01", true, true},
+	} {
+		ok, err := DecodeBoolResult(&states.PreExecResult{Result: tc.result}, "transfer", tc.wasm)
+		if err != nil {
+			t.Fatalf("Failed to decode %q: %s", tc.result, err)
+		}
+		if ok != tc.want {
+			t.Fatalf("Unexpected value for %q: got %v, want %v", tc.result, ok, tc.want)
+		}
+	}
+	for _, result := range []string{"", "0100", "02"} {
+		if _, err := DecodeBoolResult(&states.PreExecResult{Result: result}, "transfer", true); err == nil {
+			t.Fatalf("Expected an error for the WASM bool response %q", result)
+		}
+	}
+}
@@ -19,15 +19,23 @@
 package chain
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/smartcontract/states"
 	"github.com/ontio/ontology/vm/neovm"
 	"github.com/ontio/ontology/vm/neovm/errors"
 	"github.com/ontio/ontology/vm/neovm/types"
 )
 
+// maxParseSteps bounds the number of opcodes that are evaluated when parsing a
+// NeoVM payload, as the payload may come from a failed transaction or an
+// untrusted caller, and could otherwise loop forever.
+const maxParseSteps = 1 << 16
+
 var (
 	nilAddr = common.Address{}
 )
@@ -41,11 +49,55 @@ type Transfer struct {
 	Amount *big.Int
 }
 
-// ParsePayload processes the given transaction payload for transfer operations.
-func ParsePayload(code []byte) ([]*Transfer, common.Address, error) {
+// Stake represents the ONT that an account stakes with, unstakes from, or
+// withdraws from a consensus node via the governance contract. The amount is
+// in whole units of ONT. Both the amount and the peer are unset for the
+// withdrawFee method.
+type Stake struct {
+	Address common.Address
+	Amount  *big.Int
+	Peer    string
+}
+
+// Invoke represents a contract method invoked by a transaction payload. For
+// the approve method, each of the transfers represents an allowance from the
+// From address to the To address. For the governance staking methods, the
+// stakes are set instead of the transfers.
+//
+// The params are set for all methods, in the hex-encoded form that the node
+// uses for pre-execution results, so that calls to unknown methods can still
+// be inspected.
+type Invoke struct {
+	Contract  common.Address
+	Method    string
+	Params    []interface{}
+	Stakes    []*Stake
+	Transfers []*Transfer
+}
+
+// IsApprove returns whether the invoked method sets an allowance.
+func (i *Invoke) IsApprove() bool {
+	return i.Method == "approve" || i.Method == "approveV2"
+}
+
+// IsCall returns whether the invoked method is neither a transfer, an
+// approval, nor a staking method, i.e. a generic contract call.
+func (i *Invoke) IsCall() bool {
+	return i.Stakes == nil && i.Transfers == nil
+}
+
+// IsStaking returns whether the invoked method is one of the governance
+// staking methods.
+func (i *Invoke) IsStaking() bool {
+	return len(i.Stakes) > 0
+}
+
+// ParseInvoke processes the given transaction payload for the invoked
+// contract method.
+func ParseInvoke(code []byte) (*Invoke, error) {
 	e := neovm.NewExecutor(code, neovm.VmFeatureFlag{})
-	if err := e.Execute(); err != errors.ERR_NOT_SUPPORT_OPCODE {
-		return nil, nilAddr, fmt.Errorf("chain: failed to parse payload: %s", err)
+	if err := execute(e); err != errors.ERR_NOT_SUPPORT_OPCODE {
+		return nil, fmt.Errorf("chain: failed to parse payload: %s", err)
 	}
 	opcode := neovm.OpCode(e.Context.Code[e.Context.OpReader.Position()-1])
 	switch opcode {
@@ -54,64 +106,208 @@ func ParsePayload(code []byte) ([]*Transfer, common.Address, error) {
 	case neovm.SYSCALL:
 		return parseSys(e.EvalStack)
 	default:
-		return nil, nilAddr, fmt.Errorf("chain: unexpected opcode: %v", opcode)
+		return nil, fmt.Errorf("chain: unexpected opcode: %v", opcode)
+	}
+}
+
+// ParsePayload processes the given transaction payload for transfer operations.
+func ParsePayload(code []byte) ([]*Transfer, common.Address, error) {
+	inv, err := ParseInvoke(code)
+	if err != nil {
+		return nil, nilAddr, err
+	}
+	if inv.IsApprove() || inv.IsCall() || inv.IsStaking() {
+		return nil, nilAddr, fmt.Errorf("chain: unknown method: %s", inv.Method)
+	}
+	return inv.Transfers, inv.Contract, nil
+}
+
+// execute evaluates the code within the executor, as neovm.Executor.Execute
+// does, but errors once maxParseSteps opcodes have been evaluated.
+func execute(e *neovm.Executor) error {
+	e.State &^= neovm.BREAK
+	for steps := 0; e.Context != nil; steps++ {
+		if e.State == neovm.FAULT || e.State == neovm.HALT || e.State == neovm.BREAK {
+			break
+		}
+		if steps == maxParseSteps {
+			return fmt.Errorf("chain: payload exceeded %d steps", maxParseSteps)
+		}
+		opcode, eof := e.Context.ReadOpCode()
+		if eof {
+			break
+		}
+		var err error
+		e.State, err = e.ExecuteOp(opcode, e.Context)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseWasmInvoke processes the given WASM transaction payload for the invoked
+// contract method. The args are decoded for the OEP4 approve, transfer,
+// transferFrom, and transferMulti methods. For other methods, the remaining
+// args are set as a single hex-encoded param, as they can't be decoded without
+// knowing the method's signature.
+func ParseWasmInvoke(code []byte) (*Invoke, error) {
+	param := &states.WasmContractParam{}
+	if err := param.Deserialization(common.NewZeroCopySource(code)); err != nil {
+		return nil, fmt.Errorf("chain: failed to parse wasm payload: %s", err)
+	}
+	src := common.NewZeroCopySource(param.Args)
+	meth, err := src.ReadString()
+	if err != nil {
+		return nil, fmt.Errorf("chain: failed to get method: %s", err)
+	}
+	inv := &Invoke{
+		Contract: param.Address,
+		Method:   meth,
+		Params:   []interface{}{hex.EncodeToString(param.Args[src.Pos():])},
+	}
+	switch inv.Method {
+	case "approve", "transfer":
+		xfer, err := parseWasmTransfer(src)
+		if err != nil {
+			return nil, err
+		}
+		inv.Transfers = []*Transfer{xfer}
+	case "transferFrom":
+		payer, eof := src.NextAddress()
+		if eof {
+			return nil, fmt.Errorf("chain: failed to read payer field: %s", io.ErrUnexpectedEOF)
+		}
+		xfer, err := parseWasmTransfer(src)
+		if err != nil {
+			return nil, err
+		}
+		xfer.Payer = payer
+		inv.Transfers = []*Transfer{xfer}
+	case "transferMulti":
+		n, err := src.ReadVarUint()
+		if err != nil {
+			return nil, fmt.Errorf("chain: failed to read transferMulti length: %s", err)
+		}
+		if n > src.Len()/(2*common.ADDR_LEN+common.I128_SIZE) {
+			return nil, fmt.Errorf("chain: invalid transferMulti length: %d", n)
+		}
+		inv.Transfers = make([]*Transfer, n)
+		for i := range inv.Transfers {
+			inv.Transfers[i], err = parseWasmTransfer(src)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return inv, nil
+	}
+	if src.Len() != 0 {
+		return nil, fmt.Errorf("chain: unexpected trailing data in %s args", inv.Method)
+	}
+	return inv, nil
+}
+
+// ParseWasmPayload processes the given WASM transaction payload for transfer
+// operations.
+func ParseWasmPayload(code []byte) ([]*Transfer, common.Address, error) {
+	inv, err := ParseWasmInvoke(code)
+	if err != nil {
+		return nil, nilAddr, err
+	}
+	if inv.IsApprove() || inv.IsCall() {
+		return nil, nilAddr, fmt.Errorf("chain: unknown method: %s", inv.Method)
+	}
+	return inv.Transfers, inv.Contract, nil
+}
+
+func convertParams(data []types.VmValue) ([]interface{}, error) {
+	params := make([]interface{}, len(data))
+	for i, val := range data {
+		param, err := val.ConvertNeoVmValueHexString()
+		if err != nil {
+			return nil, fmt.Errorf("chain: unable to convert contract param %d: %s", i, err)
+		}
+		params[i] = param
+	}
+	return params, nil
+}
+
+func parseAddressField(data types.VmValue, field string) (common.Address, error) {
+	raw, err := data.AsBytes()
+	if err != nil {
+		return nilAddr, fmt.Errorf("chain: invalid %s field: %s", field, err)
+	}
+	addr, err := common.AddressParseFromBytes(raw)
+	if err != nil {
+		return nilAddr, fmt.Errorf("chain: unable to parse %s field: %s", field, err)
 	}
+	return addr, nil
 }
 
-func parseApp(e *neovm.Executor) ([]*Transfer, common.Address, error) {
+func parseApp(e *neovm.Executor) (*Invoke, error) {
 	var contract common.Address
 	err := e.Context.OpReader.ReadBytesInto(contract[:])
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: failed to read contract address: %s", err)
+		return nil, fmt.Errorf("chain: failed to read contract address: %s", err)
 	}
 	s := e.EvalStack
 	if contract == nilAddr {
 		raw, err := s.PopAsBytes()
 		if err != nil {
-			return nil, nilAddr, fmt.Errorf("chain: failed to get contract address: %s", err)
+			return nil, fmt.Errorf("chain: failed to get contract address: %s", err)
 		}
 		contract, err = common.AddressParseFromBytes(raw)
 		if err != nil {
-			return nil, nilAddr, fmt.Errorf("chain: unable to parse contract address: %s", err)
+			return nil, fmt.Errorf("chain: unable to parse contract address: %s", err)
 		}
 	}
 	meth, err := s.PopAsBytes()
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: failed to get method: %s", err)
+		return nil, fmt.Errorf("chain: failed to get method: %s", err)
 	}
 	xs, err := s.PopAsArray()
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: failed to get contract params: %s", err)
+		return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
 	}
 	params := xs.Data
-	switch string(meth) {
-	case "transfer":
+	inv := &Invoke{
+		Contract: contract,
+		Method:   string(meth),
+	}
+	inv.Params, err = convertParams(params)
+	if err != nil {
+		return nil, err
+	}
+	switch inv.Method {
+	case "approve", "transfer":
 		if len(params) != 3 {
-			return nil, nilAddr, fmt.Errorf("chain: unexpected transfer params length: %d", len(params))
+			return nil, fmt.Errorf("chain: unexpected %s params length: %d", inv.Method, len(params))
 		}
 		xfer, err := parseTransferFields(params)
 		if err != nil {
-			return nil, nilAddr, err
+			return nil, err
 		}
-		return []*Transfer{xfer}, contract, nil
+		inv.Transfers = []*Transfer{xfer}
 	case "transferFrom":
 		if len(params) != 4 {
-			return nil, nilAddr, fmt.Errorf("chain: unexpected transferFrom params length: %d", len(params))
+			return nil, fmt.Errorf("chain: unexpected transferFrom params length: %d", len(params))
 		}
 		xfer, err := parseTransferFromFields(params)
 		if err != nil {
-			return nil, nilAddr, err
+			return nil, err
 		}
-		return []*Transfer{xfer}, contract, nil
+		inv.Transfers = []*Transfer{xfer}
 	case "transferMulti":
 		if len(params) != 1 {
-			return nil, nilAddr, fmt.Errorf("chain: unexpected transferMulti params length: %d", len(params))
+			return nil, fmt.Errorf("chain: unexpected transferMulti params length: %d", len(params))
+		}
+		inv.Transfers, err = parseAppTransferMulti(params)
+		if err != nil {
+			return nil, err
 		}
-		xfers, err := parseAppTransferMulti(params)
-		return xfers, contract, err
-	default:
-		return nil, nilAddr, fmt.Errorf("chain: unknown method: %s", string(meth))
 	}
+	return inv, nil
 }
 
 func parseAppTransferMulti(params []types.VmValue) ([]*Transfer, error) {
@@ -122,36 +318,169 @@ func parseAppTransferMulti(params []types.VmValue) ([]*Transfer, error) {
 	return parseTransfers(xs.Data)
 }
 
-func parseSys(s *neovm.ValueStack) ([]*Transfer, common.Address, error) {
+func parseSys(s *neovm.ValueStack) (*Invoke, error) {
 	_, err := s.PopAsBytes() // ignore the version value
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: invalid params: %s", err)
+		return nil, fmt.Errorf("chain: invalid params: %s", err)
 	}
 	raw, err := s.PopAsBytes()
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: failed to get contract address: %s", err)
+		return nil, fmt.Errorf("chain: failed to get contract address: %s", err)
 	}
 	contract, err := common.AddressParseFromBytes(raw)
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: unable to parse contract address: %s", err)
+		return nil, fmt.Errorf("chain: unable to parse contract address: %s", err)
 	}
 	meth, err := s.PopAsBytes()
 	if err != nil {
-		return nil, nilAddr, fmt.Errorf("chain: failed to get method: %s", err)
+		return nil, fmt.Errorf("chain: failed to get method: %s", err)
+	}
+	inv := &Invoke{
+		Contract: contract,
+		Method:   string(meth),
+	}
+	// NOTE(tav): The remaining values on the stack are the params for the
+	// native method, which are usually a single struct.
+	params := make([]types.VmValue, s.Count())
+	for i := range params {
+		params[i], err = s.Peek(int64(i))
+		if err != nil {
+			return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
+		}
+	}
+	inv.Params, err = convertParams(params)
+	if err != nil {
+		return nil, err
 	}
-	switch string(meth) {
+	switch inv.Method {
+	case "addInitPos", "reduceInitPos":
+		stake, err := parseSysInitPos(s, inv.Method, 3)
+		if err != nil {
+			return nil, err
+		}
+		inv.Stakes = []*Stake{stake}
+	case "authorizeForPeer", "authorizeForPeerTransferFrom", "unAuthorizeForPeer", "withdraw":
+		inv.Stakes, err = parseSysStakes(s, inv.Method)
+		if err != nil {
+			return nil, err
+		}
+	case "registerCandidate", "registerCandidateTransferFrom":
+		stake, err := parseSysInitPos(s, inv.Method, 5)
+		if err != nil {
+			return nil, err
+		}
+		inv.Stakes = []*Stake{stake}
+	case "withdrawFee":
+		stake, err := parseSysWithdrawFee(s)
+		if err != nil {
+			return nil, err
+		}
+		inv.Stakes = []*Stake{stake}
+	case "approve", "approveV2":
+		xfer, err := parseSysApprove(s)
+		if err != nil {
+			return nil, err
+		}
+		inv.Transfers = []*Transfer{xfer}
 	case "transfer", "transferV2":
-		xfers, err := parseSysTransfers(s)
-		return xfers, contract, err
+		inv.Transfers, err = parseSysTransfers(s)
+		if err != nil {
+			return nil, err
+		}
 	case "transferFrom", "transferFromV2":
 		xfer, err := parseSysTransferFrom(s)
 		if err != nil {
-			return nil, contract, err
+			return nil, err
 		}
-		return []*Transfer{xfer}, contract, nil
-	default:
-		return nil, nilAddr, fmt.Errorf("chain: unknown method: %s", string(meth))
+		inv.Transfers = []*Transfer{xfer}
+	}
+	return inv, nil
+}
+
+func parseSysApprove(s *neovm.ValueStack) (*Transfer, error) {
+	xs, err := s.PopAsStruct()
+	if err != nil {
+		return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
+	}
+	if len(xs.Data) != 3 {
+		return nil, fmt.Errorf("chain: unexpected approve params length: %d", len(xs.Data))
 	}
+	return parseTransferFields(xs.Data)
+}
+
+// parseSysInitPos parses the params for the methods that change the initial
+// stake of a candidate node. The params start with the peer, the address of
+// the node owner, and the amount.
+func parseSysInitPos(s *neovm.ValueStack, method string, fields int) (*Stake, error) {
+	xs, err := s.PopAsStruct()
+	if err != nil {
+		return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
+	}
+	if len(xs.Data) != fields {
+		return nil, fmt.Errorf("chain: unexpected %s params length: %d", method, len(xs.Data))
+	}
+	peer, err := xs.Data[0].AsBytes()
+	if err != nil {
+		return nil, fmt.Errorf("chain: invalid peer field: %s", err)
+	}
+	addr, err := parseAddressField(xs.Data[1], "address")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := xs.Data[2].AsBigInt()
+	if err != nil {
+		return nil, fmt.Errorf("chain: invalid amount field: %s", err)
+	}
+	return &Stake{
+		Address: addr,
+		Amount:  amount,
+		Peer:    string(peer),
+	}, nil
+}
+
+func parseSysStakes(s *neovm.ValueStack, method string) ([]*Stake, error) {
+	xs, err := s.PopAsStruct()
+	if err != nil {
+		return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
+	}
+	if len(xs.Data) != 3 {
+		return nil, fmt.Errorf("chain: unexpected %s params length: %d", method, len(xs.Data))
+	}
+	addr, err := parseAddressField(xs.Data[0], "address")
+	if err != nil {
+		return nil, err
+	}
+	peers, err := xs.Data[1].AsArrayValue()
+	if err != nil {
+		return nil, fmt.Errorf("chain: invalid peer list field: %s", err)
+	}
+	amounts, err := xs.Data[2].AsArrayValue()
+	if err != nil {
+		return nil, fmt.Errorf("chain: invalid amount list field: %s", err)
+	}
+	if len(peers.Data) == 0 || len(peers.Data) != len(amounts.Data) {
+		return nil, fmt.Errorf(
+			"chain: mismatched %s list lengths: %d peers, %d amounts",
+			method, len(peers.Data), len(amounts.Data),
+		)
+	}
+	stakes := make([]*Stake, len(peers.Data))
+	for i, data := range peers.Data {
+		peer, err := data.AsBytes()
+		if err != nil {
+			return nil, fmt.Errorf("chain: invalid peer field: %s", err)
+		}
+		amount, err := amounts.Data[i].AsBigInt()
+		if err != nil {
+			return nil, fmt.Errorf("chain: invalid amount field: %s", err)
+		}
+		stakes[i] = &Stake{
+			Address: addr,
+			Amount:  amount,
+			Peer:    string(peer),
+		}
+	}
+	return stakes, nil
 }
 
 func parseSysTransfers(s *neovm.ValueStack) ([]*Transfer, error) {
@@ -173,6 +502,21 @@ func parseSysTransferFrom(s *neovm.ValueStack) (*Transfer, error) {
 	return parseTransferFromFields(xs.Data)
 }
 
+func parseSysWithdrawFee(s *neovm.ValueStack) (*Stake, error) {
+	xs, err := s.PopAsStruct()
+	if err != nil {
+		return nil, fmt.Errorf("chain: failed to get contract params: %s", err)
+	}
+	if len(xs.Data) != 1 {
+		return nil, fmt.Errorf("chain: unexpected withdrawFee params length: %d", len(xs.Data))
+	}
+	addr, err := parseAddressField(xs.Data[0], "address")
+	if err != nil {
+		return nil, err
+	}
+	return &Stake{Address: addr}, nil
+}
+
 func parseTransferFields(data []types.VmValue) (*Transfer, error) {
 	raw, err := data[0].AsBytes()
 	if err != nil {
@@ -236,3 +580,23 @@ func parseTransfers(params []types.VmValue) ([]*Transfer, error) {
 	}
 	return xfers, nil
 }
+
+func parseWasmTransfer(src *common.ZeroCopySource) (*Transfer, error) {
+	from, eof := src.NextAddress()
+	if eof {
+		return nil, fmt.Errorf("chain: failed to read from field: %s", io.ErrUnexpectedEOF)
+	}
+	to, eof := src.NextAddress()
+	if eof {
+		return nil, fmt.Errorf("chain: failed to read to field: %s", io.ErrUnexpectedEOF)
+	}
+	amount, eof := src.NextI128()
+	if eof {
+		return nil, fmt.Errorf("chain: failed to read amount field: %s", io.ErrUnexpectedEOF)
+	}
+	return &Transfer{
+		Amount: common.U128(amount).ToBigInt(),
+		From:   from,
+		To:     to,
+	}, nil
+}
@@ -21,10 +21,14 @@ package chain
 import (
 	"encoding/hex"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ontio/ontology/common"
 	"github.com/ontio/ontology/core/utils"
+	"github.com/ontio/ontology/smartcontract/service/native/governance"
+	"github.com/ontio/ontology/smartcontract/states"
+	"github.com/ontio/ontology/vm/neovm"
 )
 
 func TestParsePayload(t *testing.T) {
@@ -126,3 +130,243 @@ func TestParsePayload(t *testing.T) {
 			contract.ToHexString(), state.From.ToBase58(), state.To.ToBase58(), state.Amount)
 	}
 }
+
+func TestParseInvokeApprove(t *testing.T) {
+	contract, _ := common.AddressFromBase58("AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV")
+	owner, _ := common.AddressFromBase58("ASUpHyd8hsTMxKT7pCdPf1dYCZUvov2rk5")
+	spender, _ := common.AddressFromBase58("AYZ14K5FJKXC9mzS5YFfdr52E6seBqAPPU")
+	amount := big.NewInt(18289182)
+	type approveState struct {
+		From   common.Address
+		To     common.Address
+		Amount *big.Int
+	}
+	nativeApprove, err := utils.BuildNativeInvokeCode(contract, 0, "approveV2", []interface{}{
+		&approveState{From: owner, To: spender, Amount: amount},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oep4Approve, err := utils.BuildNeoVMInvokeCode(contract, []interface{}{"approve",
+		[]interface{}{owner, spender, amount}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range [][]byte{nativeApprove, oep4Approve} {
+		inv, err := ParseInvoke(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inv.IsApprove() {
+			t.Fatalf("Unexpected method for approval: %s", inv.Method)
+		}
+		if inv.Contract != contract || len(inv.Transfers) != 1 {
+			t.Fatalf("Unexpected approval: %#v", inv)
+		}
+		xfer := inv.Transfers[0]
+		if xfer.From != owner || xfer.To != spender || xfer.Amount.Cmp(amount) != 0 {
+			t.Fatalf("Unexpected approval fields: %#v", xfer)
+		}
+		if _, _, err := ParsePayload(code); err == nil {
+			t.Fatalf("Expected ParsePayload to reject approvals")
+		}
+	}
+}
+
+func TestParseInvokeCall(t *testing.T) {
+	contract, _ := common.AddressFromBase58("AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV")
+	owner, _ := common.AddressFromBase58("ASUpHyd8hsTMxKT7pCdPf1dYCZUvov2rk5")
+	neoCall, err := utils.BuildNeoVMInvokeCode(contract, []interface{}{"register",
+		[]interface{}{owner, "ontology"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nativeCall, err := utils.BuildNativeInvokeCode(contract, 0, "setName", []interface{}{owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		code   []byte
+		method string
+		params []interface{}
+	}{
+		{neoCall, "register", []interface{}{hex.EncodeToString(owner[:]), hex.EncodeToString([]byte("ontology"))}},
+		{nativeCall, "setName", []interface{}{hex.EncodeToString(owner[:])}},
+	} {
+		inv, err := ParseInvoke(tc.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inv.IsCall() || inv.Contract != contract || inv.Method != tc.method {
+			t.Fatalf("Unexpected contract call: %#v", inv)
+		}
+		if !reflect.DeepEqual(inv.Params, tc.params) {
+			t.Fatalf("Unexpected params for %s: got %#v, want %#v", tc.method, inv.Params, tc.params)
+		}
+		if _, _, err := ParsePayload(tc.code); err == nil {
+			t.Fatalf("Expected ParsePayload to reject unknown methods")
+		}
+	}
+}
+
+func TestParseInvokeLoop(t *testing.T) {
+	// NOTE(tav): A JMP with a zero offset jumps back to itself.
+	code := []byte{byte(neovm.JMP), 0, 0}
+	if _, err := ParseInvoke(code); err == nil {
+		t.Fatalf("Expected an error for a payload that loops forever")
+	}
+}
+
+func TestParseInvokeStaking(t *testing.T) {
+	gov, _ := common.AddressFromBase58("AFmseVrdL9f9oyCzZefL9tG6UbviEH9ugK")
+	staker, _ := common.AddressFromBase58("ASUpHyd8hsTMxKT7pCdPf1dYCZUvov2rk5")
+	peers := []string{
+		"02bcdd278a27e4969d48de95d6b7b086b65b8d1d4ff6509e7a9eab364a76115af7",
+		"03348c8fe64e1defb408676b6e320038bd2e592c802e27c3d7e88e68270076c2f7",
+	}
+	authorize, err := utils.BuildNativeInvokeCode(gov, 0, "authorizeForPeer", []interface{}{
+		&governance.AuthorizeForPeerParam{
+			Address:        staker,
+			PeerPubkeyList: peers,
+			PosList:        []uint32{500, 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := ParseInvoke(authorize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inv.IsStaking() || inv.Contract != gov || len(inv.Stakes) != 2 {
+		t.Fatalf("Unexpected staking invoke: %#v", inv)
+	}
+	for i, stake := range inv.Stakes {
+		if stake.Address != staker || stake.Peer != peers[i] {
+			t.Fatalf("Unexpected stake fields: %#v", stake)
+		}
+	}
+	if inv.Stakes[0].Amount.Int64() != 500 || inv.Stakes[1].Amount.Int64() != 1000 {
+		t.Fatalf("Unexpected stake amounts: %s, %s", inv.Stakes[0].Amount, inv.Stakes[1].Amount)
+	}
+	register, err := utils.BuildNativeInvokeCode(gov, 0, "registerCandidate", []interface{}{
+		&governance.RegisterCandidateParam{
+			PeerPubkey: peers[0],
+			Address:    staker,
+			InitPos:    10000,
+			Caller:     []byte("did:ont:ASUpHyd8hsTMxKT7pCdPf1dYCZUvov2rk5"),
+			KeyNo:      1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err = ParseInvoke(register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Stakes) != 1 || inv.Stakes[0].Peer != peers[0] || inv.Stakes[0].Amount.Int64() != 10000 {
+		t.Fatalf("Unexpected registerCandidate invoke: %#v", inv)
+	}
+	withdrawFee, err := utils.BuildNativeInvokeCode(gov, 0, "withdrawFee", []interface{}{
+		&governance.WithdrawFeeParam{Address: staker},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err = ParseInvoke(withdrawFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Stakes) != 1 || inv.Stakes[0].Address != staker || inv.Stakes[0].Amount != nil {
+		t.Fatalf("Unexpected withdrawFee invoke: %#v", inv)
+	}
+	if _, _, err := ParsePayload(authorize); err == nil {
+		t.Fatalf("Expected ParsePayload to reject staking methods")
+	}
+}
+
+func TestParseWasmPayload(t *testing.T) {
+	contract, _ := common.AddressFromBase58("AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV")
+	payer, _ := common.AddressFromBase58("AVpuXX3mZbjbqJ16weWzbkABxuTRuGiXbf")
+	from, _ := common.AddressFromBase58("ASUpHyd8hsTMxKT7pCdPf1dYCZUvov2rk5")
+	to, _ := common.AddressFromBase58("AYZ14K5FJKXC9mzS5YFfdr52E6seBqAPPU")
+	amount := big.NewInt(18289182)
+	transfer, err := utils.BuildWasmVMInvokeCode(contract, []interface{}{"transfer", from, to, amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transferFrom, err := utils.BuildWasmVMInvokeCode(contract, []interface{}{"transferFrom", payer, from, to, amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE(tav): The states for transferMulti are decoded as a vector of
+	// tuples, which BuildWasmContractParam can't encode, so we build the args
+	// directly.
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteString("transferMulti")
+	sink.WriteVarUint(2)
+	for _, dst := range []common.Address{to, payer} {
+		sink.WriteAddress(from)
+		sink.WriteAddress(dst)
+		val, err := common.I128FromBigInt(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink.WriteI128(val)
+	}
+	transferMulti := common.SerializeToBytes(&states.WasmContractParam{
+		Address: contract,
+		Args:    sink.Bytes(),
+	})
+	for _, tc := range []struct {
+		code  []byte
+		payer common.Address
+		tos   []common.Address
+	}{
+		{transfer, common.ADDRESS_EMPTY, []common.Address{to}},
+		{transferFrom, payer, []common.Address{to}},
+		{transferMulti, common.ADDRESS_EMPTY, []common.Address{to, payer}},
+	} {
+		xfers, addr, err := ParseWasmPayload(tc.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != contract || len(xfers) != len(tc.tos) {
+			t.Fatalf("Unexpected wasm transfers for %s: %#v", addr.ToHexString(), xfers)
+		}
+		for i, xfer := range xfers {
+			if xfer.Payer != tc.payer || xfer.From != from || xfer.To != tc.tos[i] || xfer.Amount.Cmp(amount) != 0 {
+				t.Fatalf("Unexpected wasm transfer fields: %#v", xfer)
+			}
+		}
+	}
+	approve, err := utils.BuildWasmVMInvokeCode(contract, []interface{}{"approve", from, to, amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := ParseWasmInvoke(approve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inv.IsApprove() || len(inv.Transfers) != 1 || inv.Transfers[0].To != to {
+		t.Fatalf("Unexpected wasm approval: %#v", inv)
+	}
+	call, err := utils.BuildWasmVMInvokeCode(contract, []interface{}{"setName", "ontology"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err = ParseWasmInvoke(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inv.IsCall() || inv.Method != "setName" || len(inv.Params) != 1 {
+		t.Fatalf("Unexpected wasm contract call: %#v", inv)
+	}
+	if _, _, err := ParseWasmPayload(call); err == nil {
+		t.Fatalf("Expected ParseWasmPayload to reject unknown methods")
+	}
+	if _, err := ParseWasmInvoke(transfer[:len(transfer)-1]); err == nil {
+		t.Fatalf("Expected ParseWasmInvoke to reject truncated payloads")
+	}
+}
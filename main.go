@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -31,8 +32,10 @@ import (
 
 	"github.com/ontio/ontology/common/constants"
 
+	ethcom "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	eventbus "github.com/ontio/ontology-eventbus/log"
+	"github.com/ontio/ontology-rosetta/chain"
 	"github.com/ontio/ontology-rosetta/log"
 	"github.com/ontio/ontology-rosetta/process"
 	"github.com/ontio/ontology-rosetta/services"
@@ -59,6 +62,10 @@ var (
 		Name:  "offline",
 		Usage: "Run the Rosetta server in offline mode",
 	}
+	rollbackToFlag = cli.Uint64Flag{
+		Name:  "rollback-to",
+		Usage: "Roll back the Rosetta server's internal data store to the given `<height>` before indexing",
+	}
 	serverConfigFlag = cli.StringFlag{
 		Name:  "server-config",
 		Value: "./server-config.json",
@@ -71,18 +78,26 @@ var (
 )
 
 type token struct {
-	Contract string `json:"contract"`
-	Decimals int32  `json:"decimals"`
-	Symbol   string `json:"symbol"`
-	Wasm     bool   `json:"wasm"`
+	Contract    string `json:"contract"`
+	Decimals    *int32 `json:"decimals"`
+	StartHeight uint32 `json:"start_height"`
+	Symbol      string `json:"symbol"`
+	Wasm        bool   `json:"wasm"`
 }
 
 type serverConfig struct {
-	BlockWait  uint32   `json:"block_wait_seconds"`
-	OEP4Tokens []*token `json:"oep4_tokens"`
-	Port       uint32   `json:"port"`
-	tokens     []*services.OEP4Token
-	waitTime   time.Duration
+	BlockWait      uint32   `json:"block_wait_seconds"`
+	ERC20Tokens    []*token `json:"erc20_tokens"`
+	GasLimitMargin *int     `json:"gas_limit_margin"`
+	IndexBatchSize int      `json:"index_batch_size"`
+	IndexPrefetch  int      `json:"index_prefetch"`
+	IndexWorkers   int      `json:"index_workers"`
+	OEP4Tokens     []*token `json:"oep4_tokens"`
+	Port           uint32   `json:"port"`
+	VerifyTokens   bool     `json:"verify_tokens_per_request"`
+	erc20          []*services.ERC20Token
+	tokens         []*services.OEP4Token
+	waitTime       time.Duration
 }
 
 func setupApp() *cli.App {
@@ -98,6 +113,7 @@ func setupApp() *cli.App {
 		// rosetta server settings
 		serverConfigFlag,
 		offlineFlag,
+		rollbackToFlag,
 		validateStoreFlag,
 		// base settings
 		utils.ConfigFlag,
@@ -134,6 +150,7 @@ func setupApp() *cli.App {
 	group.Flags = []cli.Flag{
 		serverConfigFlag,
 		offlineFlag,
+		rollbackToFlag,
 		validateStoreFlag,
 	}
 	cmd.AppHelpFlagGroups[idx] = group
@@ -225,7 +242,7 @@ func initServer(ctx *cli.Context,
 	node *p2pserver.P2PServer,
 	offline bool,
 ) {
-	store := initStore(cfg, scfg, offline)
+	store := initStore(ctx, cfg, scfg, offline)
 	done := make(chan bool, 1)
 	process.SetExitHandler(func() {
 		if !offline {
@@ -236,12 +253,19 @@ func initServer(ctx *cli.Context,
 	if !offline {
 		ctx, cancel := context.WithCancel(context.Background())
 		go store.IndexBlocks(ctx, services.IndexConfig{
-			Done:     done,
-			WaitTime: scfg.waitTime,
+			BatchSize: scfg.IndexBatchSize,
+			Done:      done,
+			Prefetch:  scfg.IndexPrefetch,
+			WaitTime:  scfg.waitTime,
+			Workers:   scfg.IndexWorkers,
 		})
 		process.SetExitHandler(cancel)
 	}
-	router, err := services.Router(node, store, offline)
+	router, err := services.Router(node, store, services.RouterConfig{
+		GasMargin:    uint64(*scfg.GasLimitMargin),
+		Offline:      offline,
+		VerifyTokens: scfg.VerifyTokens,
+	})
 	if err != nil {
 		log.Fatalf("Failed to load the Rosetta HTTP router: %s", err)
 	}
@@ -287,6 +311,31 @@ func initServerConfig(ctx *cli.Context) *serverConfig {
 		cfg.BlockWait = 1
 	}
 	cfg.waitTime = time.Duration(cfg.BlockWait) * time.Second
+	if cfg.GasLimitMargin == nil {
+		margin := 20
+		cfg.GasLimitMargin = &margin
+	}
+	if *cfg.GasLimitMargin < 0 {
+		log.Fatalf("Invalid gas_limit_margin value specified in %q: %d", path, *cfg.GasLimitMargin)
+	}
+	if cfg.IndexBatchSize < 0 {
+		log.Fatalf("Invalid index_batch_size value specified in %q: %d", path, cfg.IndexBatchSize)
+	}
+	if cfg.IndexBatchSize == 0 {
+		cfg.IndexBatchSize = 100
+	}
+	if cfg.IndexWorkers < 0 {
+		log.Fatalf("Invalid index_workers value specified in %q: %d", path, cfg.IndexWorkers)
+	}
+	if cfg.IndexWorkers == 0 {
+		cfg.IndexWorkers = runtime.NumCPU()
+	}
+	if cfg.IndexPrefetch < 0 {
+		log.Fatalf("Invalid index_prefetch value specified in %q: %d", path, cfg.IndexPrefetch)
+	}
+	if cfg.IndexPrefetch == 0 {
+		cfg.IndexPrefetch = 4 * cfg.IndexWorkers
+	}
 	for idx, token := range cfg.OEP4Tokens {
 		if token.Contract == "" {
 			log.Fatalf(
@@ -301,10 +350,26 @@ func initServerConfig(ctx *cli.Context) *serverConfig {
 				token.Contract, path, err,
 			)
 		}
-		if token.Decimals < 0 {
+		// NOTE(tav): Tokens with just a contract address have their metadata
+		// filled in by services.NewStore.
+		if token.Decimals == nil && token.Symbol == "" {
+			cfg.tokens = append(cfg.tokens, &services.OEP4Token{
+				Contract:    contract,
+				StartHeight: token.StartHeight,
+				Wasm:        token.Wasm,
+			})
+			continue
+		}
+		if token.Decimals == nil {
+			log.Fatalf(
+				`Missing "decimals" field for OEP4 token %q in %q`,
+				token.Contract, path,
+			)
+		}
+		if *token.Decimals < 0 {
 			log.Fatalf(
 				`Invalid "decimals" value for OEP4 token at offset %d in %q: %d`,
-				idx, path, token.Decimals,
+				idx, path, *token.Decimals,
 			)
 		}
 		if token.Symbol == "" {
@@ -314,10 +379,60 @@ func initServerConfig(ctx *cli.Context) *serverConfig {
 			)
 		}
 		cfg.tokens = append(cfg.tokens, &services.OEP4Token{
-			Contract: contract,
-			Decimals: token.Decimals,
+			Contract:    contract,
+			Decimals:    *token.Decimals,
+			StartHeight: token.StartHeight,
+			Symbol:      token.Symbol,
+			Wasm:        token.Wasm,
+		})
+	}
+	for idx, token := range cfg.ERC20Tokens {
+		if token.Contract == "" {
+			log.Fatalf(
+				`Missing "contract" field for ERC-20 token at offset %d in %q`,
+				idx, path,
+			)
+		}
+		if !ethcom.IsHexAddress(token.Contract) {
+			log.Fatalf(
+				"Invalid ERC-20 contract address %q found in %q",
+				token.Contract, path,
+			)
+		}
+		if token.Decimals == nil {
+			log.Fatalf(
+				`Missing "decimals" field for ERC-20 token %q in %q`,
+				token.Contract, path,
+			)
+		}
+		if *token.Decimals < 0 {
+			log.Fatalf(
+				`Invalid "decimals" value for ERC-20 token at offset %d in %q: %d`,
+				idx, path, *token.Decimals,
+			)
+		}
+		if token.Symbol == "" {
+			log.Fatalf(
+				`Missing "symbol" field for ERC-20 token %q in %q`,
+				token.Contract, path,
+			)
+		}
+		if token.Wasm {
+			log.Fatalf(
+				`Unexpected "wasm" field for ERC-20 token %q in %q`,
+				token.Contract, path,
+			)
+		}
+		if token.StartHeight != 0 {
+			log.Fatalf(
+				`Unexpected "start_height" field for ERC-20 token %q in %q`,
+				token.Contract, path,
+			)
+		}
+		cfg.erc20 = append(cfg.erc20, &services.ERC20Token{
+			Contract: common.Address(ethcom.HexToAddress(token.Contract)),
+			Decimals: *token.Decimals,
 			Symbol:   token.Symbol,
-			Wasm:     token.Wasm,
 		})
 	}
 	if cfg.Port > 65535 {
@@ -326,16 +441,26 @@ func initServerConfig(ctx *cli.Context) *serverConfig {
 	return cfg
 }
 
-func initStore(cfg *config.OntologyConfig, scfg *serverConfig, offline bool) *services.Store {
+func initStore(ctx *cli.Context, cfg *config.OntologyConfig, scfg *serverConfig, offline bool) *services.Store {
 	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
 	store, err := services.NewStore(filepath.Join(
 		dbDir,
 		cfg.P2PNode.NetworkName,
 		"store",
-	), scfg.tokens, offline)
+	), scfg.tokens, scfg.erc20, offline)
 	if err != nil {
 		log.Fatalf("Unable to open the internal data store: %s", err)
 	}
+	if ctx.GlobalIsSet(utils.GetFlagName(rollbackToFlag)) {
+		height := ctx.GlobalUint64(utils.GetFlagName(rollbackToFlag))
+		if height > math.MaxUint32 {
+			log.Fatalf("Invalid height %d specified for --rollback-to", height)
+		}
+		log.Infof("Rolling back the internal data store to height %d", height)
+		if err := store.RollbackTo(uint32(height)); err != nil {
+			log.Fatalf("Unable to roll back the internal data store: %s", err)
+		}
+	}
 	return store
 }
 
@@ -376,6 +501,7 @@ func runOffline(ctx *cli.Context, cfg *config.OntologyConfig, scfg *serverConfig
 
 func runOnline(ctx *cli.Context, cfg *config.OntologyConfig, scfg *serverConfig) {
 	ldg := initLedger(ctx, cfg)
+	verifyTokens(scfg)
 	txpool := initTxPool(ctx)
 	node := initP2PNode(ctx, cfg, txpool)
 	initServer(ctx, cfg, scfg, node, false)
@@ -390,11 +516,15 @@ func runOnline(ctx *cli.Context, cfg *config.OntologyConfig, scfg *serverConfig)
 
 func runValidateStore(ctx *cli.Context, cfg *config.OntologyConfig, scfg *serverConfig) {
 	initLedger(ctx, cfg)
-	store := initStore(cfg, scfg, false)
+	verifyTokens(scfg)
+	store := initStore(ctx, cfg, scfg, false)
 	log.Info("Started indexing any missing blocks")
 	store.IndexBlocks(context.Background(), services.IndexConfig{
+		BatchSize: scfg.IndexBatchSize,
 		ExitEarly: true,
+		Prefetch:  scfg.IndexPrefetch,
 		WaitTime:  scfg.waitTime,
+		Workers:   scfg.IndexWorkers,
 	})
 	log.Info("Finished indexing blocks")
 	store.Validate()
@@ -413,6 +543,29 @@ func setMaxOpenFiles() {
 	}
 }
 
+// verifyTokens checks that the contracts for the configured OEP4 tokens match
+// the config, and refuses to start the server if they don't.
+func verifyTokens(scfg *serverConfig) {
+	for _, token := range scfg.tokens {
+		// NOTE(tav): Tokens without a symbol are verified when their metadata
+		// is discovered by services.NewStore.
+		if token.Symbol == "" {
+			continue
+		}
+		err := services.VerifyOEP4Token(token)
+		if err == chain.ErrNotDeployed {
+			log.Warnf(
+				"Skipping verification of OEP4 token %s as its contract hasn't been synced yet",
+				token.Contract.ToHexString(),
+			)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Failed to verify OEP4 token %s: %s", token.Contract.ToHexString(), err)
+		}
+	}
+}
+
 func main() {
 	if err := setupApp().Run(os.Args); err != nil {
 		cmd.PrintErrorMsg(err.Error())
@@ -40,7 +40,17 @@ func (s *service) AccountBalance(ctx context.Context, r *types.AccountBalanceReq
 	if r.AccountIdentifier.SubAccount == nil {
 		return s.store.getBalance(r.BlockIdentifier, acct, r.Currencies, ontAddr, ongAddr)
 	}
-	contract, err := common.AddressFromHexString(r.AccountIdentifier.SubAccount.Address)
+	if r.AccountIdentifier.SubAccount.Address == subAcctStaking {
+		var peer []byte
+		if md := r.AccountIdentifier.SubAccount.Metadata; len(md) > 0 {
+			peer, err = getPeer(md)
+			if err != nil {
+				return nil, wrapErr(errInvalidSubAccount, err)
+			}
+		}
+		return s.store.getStakedBalance(r.BlockIdentifier, acct, r.Currencies, peer)
+	}
+	contract, err := parseContract(r.AccountIdentifier.SubAccount.Address)
 	if err != nil {
 		return nil, errInvalidContractAddress
 	}
@@ -90,44 +90,71 @@ func (s *service) BlockTransaction(ctx context.Context, r *types.BlockTransactio
 	if r.BlockIdentifier == nil {
 		return nil, errInvalidBlockIdentifier
 	}
-	info, xerr := s.store.getBlockInfo(&types.PartialBlockIdentifier{
+	id, xerr := s.store.getBlockInfo(&types.PartialBlockIdentifier{
 		Hash:  &r.BlockIdentifier.Hash,
 		Index: &r.BlockIdentifier.Index,
-	}, true)
+	}, false)
 	if xerr != nil {
 		return nil, xerr
 	}
-	hash := txhash[:]
-	for _, src := range info.block.Transactions {
-		if !bytes.Equal(src.Hash, hash) {
-			continue
+	info, src, xerr := s.store.getTransaction(txhash)
+	switch xerr {
+	case nil:
+		if info.height != id.height {
+			return nil, errInvalidTransactionHash
 		}
-		dst, xerr, err := s.transformTransaction(src)
+	case errUnknownTransactionHash:
+		// NOTE(tav): Blocks indexed before transaction locations were added
+		// need to be scanned for the transaction.
+		info, src, xerr = s.scanBlockTransaction(id, txhash)
 		if xerr != nil {
 			return nil, xerr
 		}
-		if err != nil {
-			log.Errorf(
-				"Consistency failure when decoding transaction hash %q at block %d: %s",
-				r.TransactionIdentifier.Hash, info.height, err,
-			)
-			return nil, wrapErr(errDatastoreConsistency, err)
-		}
-		return &types.BlockTransactionResponse{
-			Transaction: dst,
-		}, nil
+	default:
+		return nil, xerr
+	}
+	dst, xerr, err := s.transformTransaction(src)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if err != nil {
+		log.Errorf(
+			"Consistency failure when decoding transaction hash %q at block %d: %s",
+			r.TransactionIdentifier.Hash, info.height, err,
+		)
+		return nil, wrapErr(errDatastoreConsistency, err)
 	}
-	return nil, errInvalidTransactionHash
+	return &types.BlockTransactionResponse{
+		Transaction: dst,
+	}, nil
 }
 
 func (s *service) appendOperations(ops []*types.Operation, xfer *transferInfo, setStatus bool) []*types.Operation {
+	if op := singleOperation(xfer); op != nil {
+		op.OperationIdentifier = &types.OperationIdentifier{
+			Index: int64(len(ops)),
+		}
+		if setStatus {
+			op.Status = &statusSuccess
+		}
+		return append(ops, op)
+	}
 	neg := (&big.Int{}).Neg(xfer.amount)
 	related := false
+	xferType := opTransfer
+	switch {
+	case xfer.staking != "":
+		xferType = xfer.staking
+	case xfer.isClaim():
+		xferType = opClaimONG
+	case xfer.spender != common.ADDRESS_EMPTY:
+		xferType = opTransferFrom
+	}
 	// NOTE(tav): We specify statusSuccess for all operations, assuming that
 	// only the gas fee transfers would have been indexed for transactions
 	// that failed.
 	if xfer.from != nullAddr {
-		typ := opTransfer
+		typ := xferType
 		if xfer.to == nullAddr {
 			typ = opBurn
 		} else if xfer.isGas {
@@ -149,16 +176,21 @@ func (s *service) appendOperations(ops []*types.Operation, xfer *transferInfo, s
 		if setStatus {
 			op.Status = &statusSuccess
 		}
-		if !xfer.isNative() {
-			op.Account.SubAccount = &types.SubAccountIdentifier{
-				Address: xfer.contract.ToHexString(),
+		if typ == opTransferFrom {
+			op.Metadata = map[string]interface{}{
+				"spender": xfer.spender.ToBase58(),
 			}
 		}
+		if !xfer.isNative() {
+			op.Account.SubAccount = contractSubAccount(xfer.currency)
+		} else if typ == opWithdraw && xfer.from != govAddr {
+			op.Account.SubAccount = stakingSubAccount(xfer.peer)
+		}
 		related = true
 		ops = append(ops, op)
 	}
 	if xfer.to != nullAddr {
-		typ := opTransfer
+		typ := xferType
 		if xfer.from == nullAddr {
 			typ = opMint
 		} else if xfer.isGas {
@@ -181,9 +213,9 @@ func (s *service) appendOperations(ops []*types.Operation, xfer *transferInfo, s
 			op.Status = &statusSuccess
 		}
 		if !xfer.isNative() {
-			op.Account.SubAccount = &types.SubAccountIdentifier{
-				Address: xfer.contract.ToHexString(),
-			}
+			op.Account.SubAccount = contractSubAccount(xfer.currency)
+		} else if typ == opStake && xfer.to != govAddr {
+			op.Account.SubAccount = stakingSubAccount(xfer.peer)
 		}
 		if related {
 			op.RelatedOperations = []*types.OperationIdentifier{
@@ -192,7 +224,59 @@ func (s *service) appendOperations(ops []*types.Operation, xfer *transferInfo, s
 		}
 		ops = append(ops, op)
 	}
-	return ops
+	// NOTE(tav): Indexed stakes and withdrawals move the ONT to and from the
+	// governance contract, as on chain, so the change to the staking
+	// sub-account is returned as an additional operation.
+	var (
+		staker common.Address
+		value  *big.Int
+	)
+	switch {
+	case xferType == opStake && xfer.to == govAddr:
+		staker, value = xfer.from, xfer.amount
+	case xferType == opWithdraw && xfer.from == govAddr:
+		staker, value = xfer.to, neg
+	default:
+		return ops
+	}
+	op := &types.Operation{
+		Account: &types.AccountIdentifier{
+			Address:    staker.ToBase58(),
+			SubAccount: stakingSubAccount(xfer.peer),
+		},
+		Amount: &types.Amount{
+			Currency: xfer.currency,
+			Value:    value.String(),
+		},
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: int64(len(ops)),
+		},
+		RelatedOperations: []*types.OperationIdentifier{
+			{Index: int64(len(ops) - 1)},
+		},
+		Type: xferType,
+	}
+	if setStatus {
+		op.Status = &statusSuccess
+	}
+	return append(ops, op)
+}
+
+func (s *service) scanBlockTransaction(id *blockInfo, txhash common.Uint256) (*blockInfo, *model.Transaction, *types.Error) {
+	info, xerr := s.store.getBlockInfoRaw(&blockID{
+		byHeight: true,
+		height:   id.height,
+	}, true)
+	if xerr != nil {
+		return nil, nil, xerr
+	}
+	hash := txhash[:]
+	for _, src := range info.block.Transactions {
+		if bytes.Equal(src.Hash, hash) {
+			return info, src, nil
+		}
+	}
+	return nil, nil, errInvalidTransactionHash
 }
 
 func (s *service) transformTransaction(txn *model.Transaction) (*types.Transaction, *types.Error, error) {
@@ -219,12 +303,22 @@ func (s *service) transformTransaction(txn *model.Transaction) (*types.Transacti
 		if err != nil {
 			return nil, nil, fmt.Errorf(`services: failed to decode "to" address: %s`, err)
 		}
+		spender := common.ADDRESS_EMPTY
+		if len(xfer.Spender) > 0 {
+			spender, err = slice2addr(xfer.Spender)
+			if err != nil {
+				return nil, nil, fmt.Errorf(`services: failed to decode "spender" address: %s`, err)
+			}
+		}
 		ops = s.appendOperations(ops, &transferInfo{
 			amount:   amount,
 			contract: contract,
 			currency: info.currency,
 			from:     from,
 			isGas:    xfer.IsGas,
+			peer:     xfer.Peer,
+			spender:  spender,
+			staking:  xfer.Staking,
 			to:       to,
 		}, true)
 	}
@@ -235,3 +329,52 @@ func (s *service) transformTransaction(txn *model.Transaction) (*types.Transacti
 		},
 	}, nil, nil
 }
+
+// singleOperation returns the operation for transfers that are represented by
+// a single operation, or nil otherwise.
+func singleOperation(xfer *transferInfo) *types.Operation {
+	var op *types.Operation
+	switch {
+	case xfer.approve:
+		// NOTE(tav): Approvals don't change any balances, so the allowance is
+		// specified within the metadata instead of as an amount.
+		op = &types.Operation{
+			Account: &types.AccountIdentifier{
+				Address: xfer.from.ToBase58(),
+			},
+			Metadata: map[string]interface{}{
+				"allowance": xfer.amount.String(),
+				"currency":  xfer.currency,
+				"spender":   xfer.spender.ToBase58(),
+			},
+			Type: opApprove,
+		}
+		if !xfer.isNative() {
+			op.Account.SubAccount = contractSubAccount(xfer.currency)
+		}
+	case xfer.staking == opUnstake:
+		// NOTE(tav): Unstaked ONT remains locked until it is withdrawn, so the
+		// amount is also specified within the metadata.
+		op = &types.Operation{
+			Account: &types.AccountIdentifier{
+				Address:    xfer.from.ToBase58(),
+				SubAccount: stakingSubAccount(xfer.peer),
+			},
+			Metadata: map[string]interface{}{
+				"amount":   xfer.amount.String(),
+				"currency": xfer.currency,
+			},
+			Type: opUnstake,
+		}
+	case xfer.staking == opWithdrawFee && xfer.amount == nil:
+		// NOTE(tav): The amount of fees being withdrawn is only known once the
+		// transaction has been executed.
+		op = &types.Operation{
+			Account: &types.AccountIdentifier{
+				Address: xfer.to.ToBase58(),
+			},
+			Type: opWithdrawFee,
+		}
+	}
+	return op
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ontio/ontology-rosetta/chain"
+	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/smartcontract/states"
+)
+
+// Call implements the /call endpoint.
+func (s *service) Call(ctx context.Context, r *types.CallRequest) (*types.CallResponse, *types.Error) {
+	if s.offline {
+		return nil, errOfflineMode
+	}
+	params, xerr := decodeCallParams(r.Parameters)
+	if xerr != nil {
+		return nil, xerr
+	}
+	switch r.Method {
+	case callClaimableONG:
+		return s.callClaimableONG(params)
+	case callOEP4Balance:
+		return s.callBalanceOf(params)
+	}
+	contract, err := parseCallAddress(params.Contract)
+	if err != nil {
+		return nil, invalidCallf("invalid contract address %q: %s", params.Contract, err)
+	}
+	if params.Method == "" {
+		return nil, invalidCallf("contract method not specified")
+	}
+	args, err := parseCallArgs(params.Params)
+	if err != nil {
+		return nil, wrapErr(errInvalidCallParameters, err)
+	}
+	var res *states.PreExecResult
+	switch r.Method {
+	case callNativeInvoke:
+		res, err = chain.NativeExec(contract, params.Method, args)
+	case callNeovmInvoke:
+		res, err = chain.Exec(contract, params.Method, args)
+	case callWasmInvoke:
+		res, err = chain.WasmExec(contract, params.Method, args)
+	default:
+		return nil, wrapErr(
+			errInvalidCallMethod,
+			fmt.Errorf("services: unknown call method %q", r.Method),
+		)
+	}
+	if err != nil {
+		return nil, wrapErr(errCallFailed, err)
+	}
+	notify := make([]map[string]interface{}, len(res.Notify))
+	for i, evt := range res.Notify {
+		notify[i] = map[string]interface{}{
+			"contract": evt.ContractAddress.ToHexString(),
+			"states":   evt.States,
+		}
+	}
+	return &types.CallResponse{
+		Idempotent: false,
+		Result: map[string]interface{}{
+			"gas":    res.Gas,
+			"notify": notify,
+			"result": res.Result,
+			"state":  res.State,
+		},
+	}, nil
+}
+
+func (s *service) callBalanceOf(params *callParams) (*types.CallResponse, *types.Error) {
+	acct, err := common.AddressFromBase58(params.Account)
+	if err != nil {
+		return nil, invalidCallf("invalid account address %q: %s", params.Account, err)
+	}
+	contract, err := parseCallAddress(params.Contract)
+	if err != nil {
+		return nil, invalidCallf("invalid contract address %q: %s", params.Contract, err)
+	}
+	info, xerr := s.store.getCurrencyInfo(contract)
+	if xerr != nil {
+		return nil, xerr
+	}
+	var balance *big.Int
+	switch {
+	case info.isNative():
+		balance, err = chain.NativeBalanceOf(acct, contract)
+	case info.evm:
+		balance, err = chain.ERC20BalanceOf(acct, contract)
+	case info.wasm:
+		balance, err = chain.WasmBalanceOf(acct, contract)
+	default:
+		balance, err = chain.BalanceOf(acct, contract)
+	}
+	if err != nil {
+		return nil, wrapErr(errCallFailed, err)
+	}
+	return &types.CallResponse{
+		Idempotent: false,
+		Result: map[string]interface{}{
+			"balance":  balance.String(),
+			"currency": info.currency,
+		},
+	}, nil
+}
+
+func (s *service) callClaimableONG(params *callParams) (*types.CallResponse, *types.Error) {
+	acct, err := common.AddressFromBase58(params.Account)
+	if err != nil {
+		return nil, invalidCallf("invalid account address %q: %s", params.Account, err)
+	}
+	claimable, accrued, err := chain.UnboundONG(acct)
+	if err != nil {
+		return nil, wrapErr(errCallFailed, err)
+	}
+	info, xerr := s.store.getCurrencyInfo(ongAddr)
+	if xerr != nil {
+		return nil, xerr
+	}
+	return &types.CallResponse{
+		Idempotent: false,
+		Result: map[string]interface{}{
+			"accrued":   accrued.String(),
+			"claimable": claimable.String(),
+			"currency":  info.currency,
+		},
+	}, nil
+}
+
+func decodeCallParams(md map[string]interface{}) (*callParams, *types.Error) {
+	enc, err := json.Marshal(md)
+	if err != nil {
+		return nil, wrapErr(errInvalidCallParameters, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(enc))
+	dec.DisallowUnknownFields()
+	params := &callParams{}
+	if err := dec.Decode(params); err != nil {
+		return nil, wrapErr(errInvalidCallParameters, err)
+	}
+	return params, nil
+}
+
+// parseCallAddress parses an address in either its base58 or hex form.
+func parseCallAddress(v string) (common.Address, error) {
+	addr, err := common.AddressFromBase58(v)
+	if err == nil {
+		return addr, nil
+	}
+	return parseContract(v)
+}
+
+// parseCallArgs converts the typed call parameters into values that can be
+// used to build NeoVM, native, and WASM invocations.
+func parseCallArgs(params []*callParam) ([]interface{}, error) {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		if param == nil {
+			return nil, fmt.Errorf("services: missing call parameter at index %d", i)
+		}
+		if param.Type == "array" {
+			var elems []*callParam
+			if err := json.Unmarshal(param.Value, &elems); err != nil {
+				return nil, fmt.Errorf(
+					"services: invalid array value for call parameter %d: %s", i, err,
+				)
+			}
+			arg, err := parseCallArgs(elems)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+			continue
+		}
+		if param.Type == "bool" {
+			var v bool
+			if err := json.Unmarshal(param.Value, &v); err != nil {
+				return nil, fmt.Errorf(
+					"services: invalid bool value for call parameter %d: %s", i, err,
+				)
+			}
+			args[i] = v
+			continue
+		}
+		var raw string
+		if err := json.Unmarshal(param.Value, &raw); err != nil {
+			// NOTE(tav): Integers can also be specified as JSON numbers.
+			if param.Type != "int" {
+				return nil, fmt.Errorf(
+					"services: invalid %s value for call parameter %d: %s",
+					param.Type, i, err,
+				)
+			}
+			raw = string(param.Value)
+		}
+		var err error
+		switch param.Type {
+		case "address":
+			args[i], err = parseCallAddress(raw)
+		case "bytes":
+			args[i], err = hex.DecodeString(raw)
+		case "hash":
+			args[i], err = common.Uint256FromHexString(raw)
+		case "int":
+			v, ok := (&big.Int{}).SetString(raw, 10)
+			if !ok {
+				err = fmt.Errorf("invalid integer %s", strconv.Quote(raw))
+			}
+			args[i] = v
+		case "string":
+			args[i] = raw
+		default:
+			return nil, fmt.Errorf(
+				"services: unknown type %q for call parameter %d", param.Type, i,
+			)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(
+				"services: invalid %s value for call parameter %d: %s",
+				param.Type, i, err,
+			)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package services
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ontio/ontology/common"
+)
+
+func TestParseCallArgs(t *testing.T) {
+	var params []*callParam
+	err := json.Unmarshal([]byte(`[
+		{"type": "address", "value": "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV"},
+		{"type": "int", "value": "100000000000000000000"},
+		{"type": "int", "value": 42},
+		{"type": "array", "value": [
+			{"type": "bool", "value": true},
+			{"type": "bytes", "value": "cafe"},
+			{"type": "string", "value": "hello"}
+		]}
+	]`), &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := parseCallArgs(params)
+	if err != nil {
+		t.Fatalf("Failed to parse call args: %s", err)
+	}
+	acct, _ := common.AddressFromBase58("AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV")
+	amount, _ := (&big.Int{}).SetString("100000000000000000000", 10)
+	expected := []interface{}{
+		acct,
+		amount,
+		new(big.Int).SetInt64(42),
+		[]interface{}{true, []byte{0xca, 0xfe}, "hello"},
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Unexpected call args: got %#v, want %#v", args, expected)
+	}
+	for _, raw := range []string{
+		`[{"type": "int", "value": "1.5"}]`,
+		`[{"type": "bytes", "value": "xyz"}]`,
+		`[{"type": "float", "value": "1"}]`,
+	} {
+		params = nil
+		if err := json.Unmarshal([]byte(raw), &params); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseCallArgs(params); err == nil {
+			t.Errorf("Expected error when parsing call args: %s", raw)
+		}
+	}
+}
@@ -21,22 +21,33 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 	"reflect"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	ethcom "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ontio/ontology-crypto/ec"
 	"github.com/ontio/ontology-crypto/keypair"
 	"github.com/ontio/ontology-crypto/signature"
 	"github.com/ontio/ontology-rosetta/chain"
 	"github.com/ontio/ontology-rosetta/log"
 	"github.com/ontio/ontology-rosetta/model"
 	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/common/config"
+	"github.com/ontio/ontology/common/constants"
 	"github.com/ontio/ontology/core/payload"
 	ctypes "github.com/ontio/ontology/core/types"
 	"github.com/ontio/ontology/core/utils"
@@ -47,6 +58,13 @@ import (
 
 // ConstructionCombine implements the /construction/combine endpoint.
 func (s *service) ConstructionCombine(ctx context.Context, r *types.ConstructionCombineRequest) (*types.ConstructionCombineResponse, *types.Error) {
+	utx, xerr := decodeEvmUnsigned(r.UnsignedTransaction)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if utx != nil {
+		return combineEvmTransaction(utx, r.Signatures)
+	}
 	txn, xerr := decodeTransaction(r.UnsignedTransaction)
 	if xerr != nil {
 		return nil, xerr
@@ -55,76 +73,67 @@ func (s *service) ConstructionCombine(ctx context.Context, r *types.Construction
 	if err != nil {
 		return nil, wrapErr(errInvalidTransactionPayload, err)
 	}
-	if len(mut.Sigs) > 0 {
-		return nil, wrapErr(
-			errInvalidTransactionPayload,
-			fmt.Errorf("services: unexpected signature found in unsigned transaction"),
-		)
+	// NOTE(tav): The only signatures that an unsigned transaction can have are
+	// the placeholders for multi-sig accounts that are added by the
+	// /construction/payloads endpoint.
+	for _, sig := range mut.Sigs {
+		if len(sig.SigData) > 0 || len(sig.PubKeys) < 2 {
+			return nil, wrapErr(
+				errInvalidTransactionPayload,
+				fmt.Errorf("services: unexpected signature found in unsigned transaction"),
+			)
+		}
 	}
 	if len(r.Signatures) == 0 {
 		return nil, errInvalidSignature
 	}
 	hash := mut.Hash()
-	// TODO(ZhouPW): How should we handle the multi-sig address case?
+	multi := make([][][]byte, len(mut.Sigs))
+	for i, sig := range mut.Sigs {
+		multi[i] = make([][]byte, len(sig.PubKeys))
+	}
+	var single []ctypes.Sig
+outer:
 	for _, sig := range r.Signatures {
-		if sig.PublicKey == nil {
-			return nil, errInvalidPublicKey
-		}
-		if sig.PublicKey.CurveType != types.Edwards25519 {
-			return nil, wrapErr(
-				errInvalidPublicKey,
-				fmt.Errorf("services: unsupported key type: %q", sig.PublicKey.CurveType),
-			)
-		}
-		if len(sig.PublicKey.Bytes) != ed25519.PublicKeySize {
-			return nil, wrapErr(
-				errInvalidPublicKey,
-				fmt.Errorf(
-					"services: invalid length for ed25519 public key: %d",
-					len(sig.PublicKey.Bytes),
-				),
-			)
+		key, osig, xerr := verifySignature(sig, hash[:])
+		if xerr != nil {
+			return nil, xerr
 		}
-		key := ed25519.PublicKey(sig.PublicKey.Bytes)
-		if sig.SignatureType != types.Ed25519 {
-			return nil, wrapErr(
-				errInvalidSignature,
-				fmt.Errorf(
-					"services: unsupported signature type: %q",
-					sig.SigningPayload.SignatureType,
-				),
-			)
+		// Signatures for multi-sig accounts are collected in the same order
+		// as the public keys, as expected by the node.
+		for i, msig := range mut.Sigs {
+			for j, mkey := range msig.PubKeys {
+				if keypair.ComparePublicKey(key, mkey) {
+					multi[i][j] = osig
+					continue outer
+				}
+			}
 		}
-		if sig.SigningPayload == nil {
-			return nil, wrapErr(
-				errInvalidSignature,
-				fmt.Errorf("services: signing_payload missing"),
-			)
+		single = append(single, ctypes.Sig{
+			M:       1,
+			PubKeys: []keypair.PublicKey{key},
+			SigData: [][]byte{osig},
+		})
+	}
+	for i := range mut.Sigs {
+		sig := &mut.Sigs[i]
+		for _, osig := range multi[i] {
+			if osig != nil {
+				sig.SigData = append(sig.SigData, osig)
+			}
 		}
-		if !bytes.Equal(sig.SigningPayload.Bytes, hash[:]) {
+		if len(sig.SigData) < int(sig.M) {
+			addr, _ := ctypes.AddressFromMultiPubKeys(sig.PubKeys, int(sig.M))
 			return nil, wrapErr(
 				errInvalidSignature,
 				fmt.Errorf(
-					"services: mismatching signing_payload.hex_bytes and transaction hash",
+					"services: only %d of the %d required signatures provided for multi-sig account %s",
+					len(sig.SigData), sig.M, addr.ToBase58(),
 				),
 			)
 		}
-		if !ed25519.Verify(key, sig.SigningPayload.Bytes, sig.Bytes) {
-			return nil, errInvalidSignature
-		}
-		osig, err := signature.Serialize(&signature.Signature{
-			Scheme: signature.SHA512withEDDSA,
-			Value:  sig.Bytes,
-		})
-		if err != nil {
-			return nil, wrapErr(errInvalidSignature, err)
-		}
-		mut.Sigs = append(mut.Sigs, ctypes.Sig{
-			M:       1,
-			PubKeys: []keypair.PublicKey{key},
-			SigData: [][]byte{osig},
-		})
 	}
+	mut.Sigs = append(mut.Sigs, single...)
 	txn, err = mut.IntoImmutable()
 	if err != nil {
 		return nil, wrapErr(errInternal, err)
@@ -138,48 +147,47 @@ func (s *service) ConstructionCombine(ctx context.Context, r *types.Construction
 
 // ConstructionDerive implements the /construction/derive endpoint.
 func (s *service) ConstructionDerive(ctx context.Context, r *types.ConstructionDeriveRequest) (*types.ConstructionDeriveResponse, *types.Error) {
-	if r.PublicKey == nil {
-		return nil, errInvalidPublicKey
+	key, xerr := parsePublicKey(r.PublicKey)
+	if xerr != nil {
+		return nil, xerr
 	}
-	var key keypair.PublicKey
-	switch r.PublicKey.CurveType {
-	case types.Edwards25519:
-		if len(r.PublicKey.Bytes) != ed25519.PublicKeySize {
+	addr := ctypes.AddressFromPubKey(key)
+	multi, xerr := getMultisig(r.Metadata)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if multi != nil {
+		if !multi.hasKey(key) {
 			return nil, wrapErr(
 				errInvalidPublicKey,
-				fmt.Errorf(
-					"services: invalid length for an ed25519 key: %d",
-					len(r.PublicKey.Bytes),
-				),
+				fmt.Errorf("services: public_key is not one of metadata.public_keys"),
 			)
 		}
-		key = ed25519.PublicKey(r.PublicKey.Bytes)
-	default:
-		return nil, wrapErr(
-			errInvalidPublicKey,
-			fmt.Errorf("services: unsupported key type: %s", r.PublicKey.CurveType),
-		)
+		addr = multi.addr
 	}
-	addr := ctypes.AddressFromPubKey(key)
 	contract, xerr := s.getContract((r.Metadata))
 	if xerr != nil {
 		return nil, xerr
 	}
-	if contract == "" {
-		return &types.ConstructionDeriveResponse{
-			AccountIdentifier: &types.AccountIdentifier{
-				Address: addr.ToBase58(),
-			},
-		}, nil
-	}
-	return &types.ConstructionDeriveResponse{
+	resp := &types.ConstructionDeriveResponse{
 		AccountIdentifier: &types.AccountIdentifier{
 			Address: addr.ToBase58(),
-			SubAccount: &types.SubAccountIdentifier{
-				Address: contract,
-			},
 		},
-	}, nil
+	}
+	if contract != "" {
+		resp.AccountIdentifier.SubAccount = &types.SubAccountIdentifier{
+			Address: contract,
+		}
+	}
+	// NOTE(tav): The addresses for secp256k1 keys are derived in the same way
+	// as on Ethereum, so we also return their hex encoding for use with EVM
+	// tooling.
+	if _, ok := key.(*ec.EthereumPublicKey); ok {
+		resp.Metadata = map[string]interface{}{
+			"evm_address": ethcom.Address(addr).Hex(),
+		}
+	}
+	return resp, nil
 }
 
 // ConstructionHash implements the /construction/hash endpoint.
@@ -200,18 +208,72 @@ func (s *service) ConstructionMetadata(ctx context.Context, r *types.Constructio
 	if xerr := decodeProtobuf(r.Options, opts); xerr != nil {
 		return nil, xerr
 	}
-	gasPrice, err := getGasPrice()
-	// NOTE(tav): We assume that the gas prices will only go up over time, which
-	// may not be true.
-	if err == nil && gasPrice > opts.GasPrice {
+	// NOTE(tav): An explicit gas price from the caller is used as is, as it has
+	// already been checked by /construction/preprocess. Otherwise, we estimate
+	// it from recent blocks, and scale it by any multiplier, while making sure
+	// that it doesn't drop below the minimum accepted by nodes.
+	if opts.GasPrice == 0 {
+		gasPrice, err := getGasPrice()
+		if err != nil {
+			gasPrice = defaultGasPrice
+		}
+		if opts.FeeMultiplier > 0 {
+			gasPrice = uint64(math.Ceil(float64(gasPrice) * opts.FeeMultiplier))
+		}
+		if gasPrice < defaultGasPrice {
+			gasPrice = defaultGasPrice
+		}
 		opts.GasPrice = gasPrice
-	} else if opts.GasPrice < defaultGasPrice {
-		opts.GasPrice = defaultGasPrice
 	}
-	if opts.GasLimit < minGasLimit {
-		opts.GasLimit = minGasLimit
+	evm, xerr := isEvmTransfer(opts, r.PublicKeys)
+	if xerr != nil {
+		return nil, xerr
+	}
+	opts.Evm = evm
+	// NOTE(tav): A zero gas limit means that one wasn't specified by the
+	// caller, in which case we derive it by pre-executing the transaction.
+	estimate := opts.GasLimit == 0
+	gasLimit := minGasLimit
+	if evm {
+		gasLimit = evmMinGasLimit
+		if !bytes.Equal(opts.Contract, ongAddr[:]) {
+			gasLimit = evmTokenGasLimit
+		}
+	}
+	if opts.GasLimit < gasLimit {
+		opts.GasLimit = gasLimit
+	}
+	if !evm {
+		if s.verifyTokens {
+			if xerr := s.verifyToken(opts); xerr != nil {
+				return nil, xerr
+			}
+		}
+		if xerr := s.setGasLimit(opts, estimate); xerr != nil {
+			return nil, xerr
+		}
 	}
-	if opts.Nonce == 0 {
+	switch {
+	case evm:
+		// NOTE(tav): EVM transactions use the account's sequential nonce,
+		// which we get from the node unless one has been specified.
+		if opts.Nonce == 0 {
+			acct, err := actor.GetEthAccount(ethcom.BytesToAddress(opts.From))
+			if err != nil {
+				return nil, wrapErr(errInternal, err)
+			}
+			if acct.Nonce > math.MaxUint32 {
+				return nil, wrapErr(
+					errInvalidNonce,
+					fmt.Errorf("services: EVM nonce %d is outside the uint32 range", acct.Nonce),
+				)
+			}
+			opts.Nonce = uint32(acct.Nonce)
+		}
+		if _, err := s.constructEvmTransfer(opts); err != nil {
+			return nil, wrapErr(errInvalidConstructOptions, err)
+		}
+	case opts.Nonce == 0:
 		buf := make([]byte, 8)
 		for i := 0; i < 100; i++ {
 			n, err := rand.Read(buf)
@@ -234,7 +296,7 @@ func (s *service) ConstructionMetadata(ctx context.Context, r *types.Constructio
 		if opts.Nonce == 0 {
 			return nil, errNonceGenerationFailed
 		}
-	} else {
+	default:
 		txn, err := s.constructTransfer(opts)
 		if err != nil {
 			return nil, wrapErr(errInvalidConstructOptions, err)
@@ -253,6 +315,14 @@ func (s *service) ConstructionMetadata(ctx context.Context, r *types.Constructio
 			)
 		}
 	}
+	fee, xerr := checkMaxFee(opts)
+	if xerr != nil {
+		return nil, xerr
+	}
+	ong, xerr := s.store.getCurrencyInfo(ongAddr)
+	if xerr != nil {
+		return nil, xerr
+	}
 	log.Infof("Metadata opts: %s", opts)
 	enc, err := proto.Marshal(opts)
 	if err != nil {
@@ -262,34 +332,39 @@ func (s *service) ConstructionMetadata(ctx context.Context, r *types.Constructio
 		Metadata: map[string]interface{}{
 			"protobuf": hex.EncodeToString(enc),
 		},
+		SuggestedFee: []*types.Amount{{
+			Currency: ong.currency,
+			Value:    fee.String(),
+		}},
 	}, nil
 }
 
 // ConstructionParse implements the /construction/parse endpoint.
 func (s *service) ConstructionParse(ctx context.Context, r *types.ConstructionParseRequest) (*types.ConstructionParseResponse, *types.Error) {
+	if !r.Signed {
+		utx, xerr := decodeEvmUnsigned(r.Transaction)
+		if xerr != nil {
+			return nil, xerr
+		}
+		if utx != nil {
+			return s.parseEvmTransaction(utx.Tx, common.Address(utx.From), false)
+		}
+	}
 	txn, xerr := decodeTransaction(r.Transaction)
 	if xerr != nil {
 		return nil, xerr
 	}
-	ops, cinfo, xerr := s.parsePayload(txn.Payload)
+	if txn.IsEipTx() {
+		tx, err := txn.GetEIP155Tx()
+		if err != nil {
+			return nil, wrapErr(errInvalidTransactionPayload, err)
+		}
+		return s.parseEvmTransaction(tx, txn.Payer, true)
+	}
+	ops, cinfo, xerr := s.parsePayload(txn)
 	if xerr != nil {
 		return nil, xerr
 	}
-	// NOTE(tav): We assume that we're dealing with a transaction created by
-	// ourselves, and that parsePayload will only return 2 operations. The first
-	// for the "transfer from", and the second for the "transfer to".
-	if len(ops) != 2 {
-		return nil, wrapErr(
-			errInternal,
-			fmt.Errorf("unexpected number of operations in transaction: %d", len(ops)),
-		)
-	}
-	if ops[0].Amount == nil || len(ops[0].Amount.Value) == 0 || ops[0].Amount.Value[0] != '-' {
-		return nil, wrapErr(
-			errInternal,
-			fmt.Errorf(`unexpected "transfer from" operation in transaction: %v`, ops[0]),
-		)
-	}
 	var signers []*types.AccountIdentifier
 	if r.Signed {
 		if len(txn.Sigs) == 0 {
@@ -298,6 +373,7 @@ func (s *service) ConstructionParse(ctx context.Context, r *types.ConstructionPa
 				fmt.Errorf("services: signature(s) not present in signed transaction data"),
 			)
 		}
+		hash := txn.Hash()
 		for _, raw := range txn.Sigs {
 			sig, err := raw.GetSig()
 			if err != nil {
@@ -309,25 +385,32 @@ func (s *service) ConstructionParse(ctx context.Context, r *types.ConstructionPa
 					),
 				)
 			}
-			if len(sig.PubKeys) != 1 {
+			keys := sig.PubKeys
+			switch {
+			case len(keys) == 0:
 				return nil, wrapErr(
 					errInvalidTransactionPayload,
-					fmt.Errorf(
-						"services: unexpected number of signatures in transaction data: %d",
-						len(sig.PubKeys),
-					),
+					fmt.Errorf("services: no public keys found for signature in transaction data"),
 				)
+			case len(keys) > 1:
+				// NOTE(tav): For multi-sig accounts, each of the keys that
+				// signed the transaction is reported as a signer, matching the
+				// signing payloads returned by /construction/payloads.
+				keys, err = multisigSigners(sig, hash[:])
+				if err != nil {
+					return nil, wrapErr(errInvalidSignature, err)
+				}
 			}
-			addr := ctypes.AddressFromPubKey(sig.PubKeys[0])
-			acct := &types.AccountIdentifier{
-				Address: addr.ToBase58(),
-			}
-			if !cinfo.isNative() {
-				acct.SubAccount = &types.SubAccountIdentifier{
-					Address: cinfo.contract.ToHexString(),
+			for _, key := range keys {
+				addr := ctypes.AddressFromPubKey(key)
+				acct := &types.AccountIdentifier{
+					Address: addr.ToBase58(),
 				}
+				if cinfo != nil && !cinfo.isNative() {
+					acct.SubAccount = contractSubAccount(cinfo.currency)
+				}
+				signers = append(signers, acct)
 			}
-			signers = append(signers, acct)
 		}
 	}
 	return &types.ConstructionParseResponse{
@@ -348,50 +431,113 @@ func (s *service) ConstructionPayloads(ctx context.Context, r *types.Constructio
 	if xerr := decodeProtobuf(r.Metadata, opts); xerr != nil {
 		return nil, xerr
 	}
-	xfer, xerr := s.validateOps(r.Operations)
+	xfers, xerr := s.validateOps(r.Operations)
 	if xerr != nil {
 		return nil, xerr
 	}
-	if !bytes.Equal(opts.Amount, xfer.amount.Bytes()) {
+	xfer := xfers[0]
+	expected := &model.ConstructOptions{}
+	encodeTransfers(expected, xfers)
+	if !bytes.Equal(opts.Amount, expected.Amount) {
 		return nil, invalidConstructf("amount does not match value from operations")
 	}
-	if !bytes.Equal(opts.Contract, xfer.contract[:]) {
+	if !bytes.Equal(opts.Contract, expected.Contract) {
 		return nil, invalidConstructf("contract does not match value from operations")
 	}
-	if !bytes.Equal(opts.From, xfer.from[:]) {
+	if !bytes.Equal(opts.From, expected.From) {
 		return nil, invalidConstructf("from field does not match value from operations")
 	}
-	if !bytes.Equal(opts.To, xfer.to[:]) {
+	if !bytes.Equal(opts.To, expected.To) {
 		return nil, invalidConstructf("to field does not match value from operations")
 	}
+	if opts.Approve != expected.Approve || !bytes.Equal(opts.Spender, expected.Spender) {
+		return nil, invalidConstructf("spender does not match value from operations")
+	}
+	if opts.Staking != expected.Staking {
+		return nil, invalidConstructf("staking type does not match operations")
+	}
+	if len(opts.Recipients) != len(expected.Recipients) {
+		return nil, invalidConstructf("number of recipients does not match operations")
+	}
+	for i, recipient := range opts.Recipients {
+		if !proto.Equal(recipient, expected.Recipients[i]) {
+			return nil, invalidConstructf("recipients do not match values from operations")
+		}
+	}
+	if opts.Evm {
+		return s.evmPayloads(opts, xfer)
+	}
 	txn, err := s.constructTransfer(opts)
 	if err != nil {
 		return nil, wrapErr(errInvalidConstructOptions, err)
 	}
+	multi, err := decodeMultisig(opts)
+	if err != nil {
+		return nil, wrapErr(errInvalidConstructOptions, err)
+	}
+	if multi != nil {
+		// NOTE(tav): We add a placeholder signature for the multi-sig account
+		// so that /construction/combine knows its public keys and threshold.
+		mut, err := txn.IntoMutable()
+		if err != nil {
+			return nil, wrapErr(errInternal, err)
+		}
+		mut.Sigs = append(mut.Sigs, ctypes.Sig{
+			M:       multi.m,
+			PubKeys: multi.keys,
+		})
+		txn, err = mut.IntoImmutable()
+		if err != nil {
+			return nil, wrapErr(errInternal, err)
+		}
+	}
 	sink := common.ZeroCopySink{}
 	txn.Serialization(&sink)
 	hash := txn.Hash()
-	acct := &types.AccountIdentifier{
-		Address: xfer.from.ToBase58(),
+	signers := []common.Address{xfer.signer()}
+	if txn.Payer != signers[0] {
+		signers = append(signers, txn.Payer)
 	}
-	if !xfer.isNative() {
-		acct.SubAccount = &types.SubAccountIdentifier{
-			Address: xfer.contract.ToHexString(),
+	keys := map[common.Address]keypair.PublicKey{}
+	for _, pk := range r.PublicKeys {
+		key, xerr := parsePublicKey(pk)
+		if xerr != nil {
+			return nil, xerr
+		}
+		keys[ctypes.AddressFromPubKey(key)] = key
+	}
+	var payloads []*types.SigningPayload
+	for _, signer := range signers {
+		// NOTE(tav): Each of the keys for a multi-sig account gets its own
+		// signing payload, identified by the key's single-sig address.
+		addrs := []common.Address{signer}
+		if multi != nil && signer == multi.addr {
+			addrs = addrs[:0]
+			for _, key := range multi.keys {
+				addr := ctypes.AddressFromPubKey(key)
+				addrs = append(addrs, addr)
+				keys[addr] = key
+			}
+		}
+		for _, addr := range addrs {
+			acct := &types.AccountIdentifier{
+				Address: addr.ToBase58(),
+			}
+			if !xfer.isNative() {
+				acct.SubAccount = contractSubAccount(xfer.currency)
+			}
+			// NOTE(tav): Signers that didn't provide a public key are assumed
+			// to be using ed25519 keys.
+			msg, typ := hash[:], types.Ed25519
+			if key, ok := keys[addr]; ok {
+				msg, typ = signingPayload(key, hash[:])
+			}
+			payloads = append(payloads, &types.SigningPayload{
+				AccountIdentifier: acct,
+				Bytes:             msg,
+				SignatureType:     typ,
+			})
 		}
-	}
-	payloads := []*types.SigningPayload{{
-		AccountIdentifier: acct,
-		Bytes:             hash[:],
-		SignatureType:     types.Ed25519,
-	}}
-	if txn.Payer != xfer.from {
-		payer := *acct
-		payer.Address = txn.Payer.ToBase58()
-		payloads = append(payloads, &types.SigningPayload{
-			AccountIdentifier: &payer,
-			Bytes:             hash[:],
-			SignatureType:     types.Ed25519,
-		})
 	}
 	return &types.ConstructionPayloadsResponse{
 		Payloads:            payloads,
@@ -401,17 +547,19 @@ func (s *service) ConstructionPayloads(ctx context.Context, r *types.Constructio
 
 // ConstructionPreprocess implements the /construction/preprocess endpoint.
 func (s *service) ConstructionPreprocess(ctx context.Context, r *types.ConstructionPreprocessRequest) (*types.ConstructionPreprocessResponse, *types.Error) {
-	if len(r.MaxFee) > 0 {
-		return nil, wrapErr(
-			errInvalidRequestField,
-			fmt.Errorf("services: unsupported field: max_fee"),
-		)
+	maxFee, xerr := s.getMaxFee(r.MaxFee)
+	if xerr != nil {
+		return nil, xerr
 	}
+	multiplier := 0.0
 	if r.SuggestedFeeMultiplier != nil {
-		return nil, wrapErr(
-			errInvalidRequestField,
-			fmt.Errorf("services: unsupported field: suggested_fee_multiplier"),
-		)
+		multiplier = *r.SuggestedFeeMultiplier
+		if multiplier <= 0 || math.IsInf(multiplier, 0) || math.IsNaN(multiplier) {
+			return nil, wrapErr(
+				errInvalidRequestField,
+				fmt.Errorf("services: invalid suggested_fee_multiplier: %v", multiplier),
+			)
+		}
 	}
 	gasLimit, err := getUint64Field(r.Metadata, "gas_limit")
 	if err != nil {
@@ -421,6 +569,15 @@ func (s *service) ConstructionPreprocess(ctx context.Context, r *types.Construct
 	if err != nil {
 		return nil, wrapErr(errInvalidGasPrice, err)
 	}
+	if gasPrice != 0 && gasPrice < defaultGasPrice {
+		return nil, wrapErr(
+			errInvalidGasPrice,
+			fmt.Errorf(
+				"services: gas_price of %d is below the minimum of %d",
+				gasPrice, defaultGasPrice,
+			),
+		)
+	}
 	nonce, err := getUint64Field(r.Metadata, "nonce")
 	if err != nil {
 		return nil, wrapErr(errInvalidNonce, err)
@@ -429,32 +586,76 @@ func (s *service) ConstructionPreprocess(ctx context.Context, r *types.Construct
 	if xerr != nil {
 		return nil, xerr
 	}
-	xfer, xerr := s.validateOps(r.Operations)
+	xfers, xerr := s.validateOps(r.Operations)
 	if xerr != nil {
 		return nil, xerr
 	}
+	xfer := xfers[0]
+	signer := xfer.signer()
 	if payer == common.ADDRESS_EMPTY {
-		payer = xfer.from
+		payer = signer
+	}
+	multi, xerr := getMultisig(r.Metadata)
+	if xerr != nil {
+		return nil, xerr
 	}
 	opts := &model.ConstructOptions{
-		Amount:   xfer.amount.Bytes(),
-		Contract: xfer.contract[:],
-		From:     xfer.from[:],
-		GasLimit: gasLimit,
-		GasPrice: gasPrice,
-		Nonce:    uint32(nonce),
-		Payer:    payer[:],
-		To:       xfer.to[:],
+		FeeMultiplier: multiplier,
+		GasLimit:      gasLimit,
+		GasPrice:      gasPrice,
+		MaxFee:        maxFee,
+		Nonce:         uint32(nonce),
+		Payer:         payer[:],
+	}
+	// NOTE(tav): When both the gas limit and price have been specified, we can
+	// refuse a transaction that would exceed the max fee upfront. Otherwise,
+	// this is checked once the values are known in /construction/metadata.
+	if gasLimit > 0 && gasPrice > 0 {
+		if _, xerr := checkMaxFee(opts); xerr != nil {
+			return nil, xerr
+		}
+	}
+	encodeTransfers(opts, xfers)
+	if multi != nil {
+		if multi.addr != signer && multi.addr != payer {
+			return nil, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf(
+					"services: multi-sig account %s is neither the signer nor the payer",
+					multi.addr.ToBase58(),
+				),
+			)
+		}
+		opts.MultisigM = uint32(multi.m)
+		for _, key := range multi.keys {
+			opts.MultisigKeys = append(opts.MultisigKeys, keypair.SerializePublicKey(key))
+		}
 	}
 	log.Infof("Preprocess opts: %s", opts)
 	enc, err := proto.Marshal(opts)
 	if err != nil {
 		return nil, wrapErr(errProtobuf, err)
 	}
+	// NOTE(tav): The public keys of the signers are needed so that
+	// /construction/payloads can return the right signature type for each key.
+	signers := []common.Address{signer}
+	if payer != signer {
+		signers = append(signers, payer)
+	}
+	var required []*types.AccountIdentifier
+	for _, signer := range signers {
+		if multi != nil && signer == multi.addr {
+			continue
+		}
+		required = append(required, &types.AccountIdentifier{
+			Address: signer.ToBase58(),
+		})
+	}
 	return &types.ConstructionPreprocessResponse{
 		Options: map[string]interface{}{
 			"protobuf": hex.EncodeToString(enc),
 		},
+		RequiredPublicKeys: required,
 	}, nil
 }
 
@@ -474,7 +675,9 @@ func (s *service) ConstructionSubmit(ctx context.Context, r *types.ConstructionS
 	return txhash2response(txn.Hash())
 }
 
-func (s *service) constructTransfer(opts *model.ConstructOptions) (*ctypes.Transaction, error) {
+// constructEvmTransfer builds the EIP-155 transaction for a transfer of ONG, or
+// of an ERC-20 token, from an account with a secp256k1 key.
+func (s *service) constructEvmTransfer(opts *model.ConstructOptions) (*ethtypes.Transaction, error) {
 	contract, err := common.AddressParseFromBytes(opts.Contract)
 	if err != nil {
 		return nil, err
@@ -487,6 +690,12 @@ func (s *service) constructTransfer(opts *model.ConstructOptions) (*ctypes.Trans
 	if err != nil {
 		return nil, err
 	}
+	if payer != from {
+		return nil, fmt.Errorf("services: EVM transactions must be paid for by the sender")
+	}
+	if len(opts.MultisigKeys) > 0 || len(opts.Recipients) > 0 || len(opts.Spender) > 0 || opts.Staking != "" {
+		return nil, fmt.Errorf("services: only single transfers are supported for EVM transactions")
+	}
 	to, err := common.AddressParseFromBytes(opts.To)
 	if err != nil {
 		return nil, err
@@ -499,32 +708,154 @@ func (s *service) constructTransfer(opts *model.ConstructOptions) (*ctypes.Trans
 		)
 	}
 	amount := (&big.Int{}).SetBytes(opts.Amount)
+	// NOTE(tav): EVM gas prices are denominated in units of 10^-18 ONG, and
+	// must be a multiple of the node's native unit of 10^-9 ONG.
+	price := (&big.Int{}).Mul(
+		(&big.Int{}).SetUint64(opts.GasPrice), big.NewInt(constants.GWei),
+	)
+	nonce := uint64(opts.Nonce)
+	switch {
+	case contract == ongAddr:
+		return ethtypes.NewTransaction(
+			nonce, ethcom.Address(to), amount, opts.GasLimit, price, nil,
+		), nil
+	case cinfo.evm:
+		data, err := s.store.parsedAbi.Pack("transfer", ethcom.Address(to), amount)
+		if err != nil {
+			return nil, fmt.Errorf("services: unable to encode ERC-20 transfer: %s", err)
+		}
+		return ethtypes.NewTransaction(
+			nonce, ethcom.Address(contract), big.NewInt(0), opts.GasLimit, price, data,
+		), nil
+	default:
+		return nil, fmt.Errorf(
+			"services: %s cannot be transferred with an EVM transaction",
+			cinfo.currency.Symbol,
+		)
+	}
+}
+
+func (s *service) constructTransfer(opts *model.ConstructOptions) (*ctypes.Transaction, error) {
+	contract, err := common.AddressParseFromBytes(opts.Contract)
+	if err != nil {
+		return nil, err
+	}
+	from, err := common.AddressParseFromBytes(opts.From)
+	if err != nil {
+		return nil, err
+	}
+	payer, err := common.AddressParseFromBytes(opts.Payer)
+	if err != nil {
+		return nil, err
+	}
+	cinfo, xerr := s.store.getCurrencyInfo(contract)
+	if xerr != nil {
+		return nil, fmt.Errorf(
+			"services: unable to find currency info for %s",
+			contract.ToHexString(),
+		)
+	}
+	type state struct {
+		From   common.Address
+		To     common.Address
+		Amount *big.Int
+	}
+	var (
+		method string
+		params []interface{}
+	)
+	native := cinfo.isNative()
+	switch {
+	case opts.Staking != "":
+		contract = govAddr
+		method, params, err = stakingParams(opts, from)
+		if err != nil {
+			return nil, err
+		}
+	case opts.Approve:
+		spender, err := common.AddressParseFromBytes(opts.Spender)
+		if err != nil {
+			return nil, err
+		}
+		amount := (&big.Int{}).SetBytes(opts.Amount)
+		if native {
+			method = "approveV2"
+			params = []interface{}{&state{
+				From:   from,
+				To:     spender,
+				Amount: amount,
+			}}
+		} else {
+			method = "approve"
+			params = []interface{}{from, spender, amount}
+		}
+	case len(opts.Spender) > 0:
+		spender, err := common.AddressParseFromBytes(opts.Spender)
+		if err != nil {
+			return nil, err
+		}
+		to, err := common.AddressParseFromBytes(opts.To)
+		if err != nil {
+			return nil, err
+		}
+		amount := (&big.Int{}).SetBytes(opts.Amount)
+		if native {
+			method = "transferFromV2"
+			params = []interface{}{&struct {
+				Sender common.Address
+				From   common.Address
+				To     common.Address
+				Amount *big.Int
+			}{
+				Sender: spender,
+				From:   from,
+				To:     to,
+				Amount: amount,
+			}}
+		} else {
+			method = "transferFrom"
+			params = []interface{}{spender, from, to, amount}
+		}
+	default:
+		var states []*state
+		for _, recipient := range getRecipients(opts) {
+			to, err := common.AddressParseFromBytes(recipient.To)
+			if err != nil {
+				return nil, err
+			}
+			states = append(states, &state{
+				From:   from,
+				To:     to,
+				Amount: (&big.Int{}).SetBytes(recipient.Amount),
+			})
+		}
+		switch {
+		case native:
+			method = "transferV2"
+			params = []interface{}{states}
+		case len(states) > 1:
+			if cinfo.wasm {
+				return nil, fmt.Errorf("services: batch transfers are not supported for WASM contracts")
+			}
+			method = "transferMulti"
+			params = []interface{}{states}
+		default:
+			method = "transfer"
+			params = []interface{}{from, states[0].To, states[0].Amount}
+		}
+	}
 	typ := ctypes.InvokeNeo
 	var code []byte
-	if cinfo.isNative() {
-		params := []interface{}{struct {
-			From   common.Address
-			To     common.Address
-			Amount *big.Int
-		}{
-			From:   from,
-			To:     to,
-			Amount: amount,
-		}}
-		code, err = utils.BuildNativeInvokeCode(
-			contract, 0, "transferV2", []interface{}{params},
-		)
+	if native {
+		code, err = utils.BuildNativeInvokeCode(contract, 0, method, params)
 	} else if cinfo.wasm {
-		// TODO(tav): The params need to be verified for WASM contracts.
-		code, err = utils.BuildWasmVMInvokeCode(contract, []interface{}{
-			"transfer", []interface{}{from, to, amount},
-		})
+		// NOTE(tav): WASM contracts decode their args in sequence, so the
+		// params follow the method directly, as with chain.WasmExec. The
+		// contract's method signatures are checked by VerifyOEP4Token.
+		code, err = utils.BuildWasmVMInvokeCode(contract, append([]interface{}{method}, params...))
 		typ = ctypes.InvokeWasm
 	} else {
-		// TODO(tav): The params need to be verified for Neo contracts.
-		code, err = utils.BuildNeoVMInvokeCode(contract, []interface{}{
-			"transfer", []interface{}{from, to, amount},
-		})
+		code, err = utils.BuildNeoVMInvokeCode(contract, []interface{}{method, params})
 	}
 	if err != nil {
 		return nil, fmt.Errorf("services: unable to build transaction invoke code: %s", err)
@@ -543,10 +874,89 @@ func (s *service) constructTransfer(opts *model.ConstructOptions) (*ctypes.Trans
 	return mut.IntoImmutable()
 }
 
-func (s *service) getContract(md map[string]interface{}) (string, *types.Error) {
-	if md == nil {
-		return "", nil
-	}
+// evmOperations returns the operations for an EVM transaction from the given
+// sender. Only transfers of ONG and of ERC-20 tokens are supported.
+func (s *service) evmOperations(tx *ethtypes.Transaction, from common.Address) ([]*types.Operation, *currencyInfo, *types.Error) {
+	if tx.To() == nil {
+		return nil, nil, wrapErr(
+			errInvalidTransactionPayload,
+			fmt.Errorf("services: EVM contract deployments are not supported"),
+		)
+	}
+	contract := ongAddr
+	to := common.Address(*tx.To())
+	amount := tx.Value()
+	if data := tx.Data(); len(data) > 0 {
+		method, err := s.store.parsedAbi.MethodById(data)
+		if err != nil || method.Name != "transfer" || amount.Sign() != 0 {
+			return nil, nil, wrapErr(
+				errInvalidTransactionPayload,
+				fmt.Errorf("services: unsupported EVM contract call"),
+			)
+		}
+		args, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, nil, wrapErr(errInvalidTransactionPayload, err)
+		}
+		contract = to
+		to = common.Address(args[0].(ethcom.Address))
+		amount = args[1].(*big.Int)
+	}
+	info, xerr := s.store.getCurrencyInfo(contract)
+	if xerr != nil {
+		return nil, nil, xerr
+	}
+	if contract != ongAddr && !info.evm {
+		return nil, nil, wrapErr(
+			errInvalidTransactionPayload,
+			fmt.Errorf("services: %s is not an ERC-20 token", contract.ToHexString()),
+		)
+	}
+	ops := s.appendOperations([]*types.Operation{}, &transferInfo{
+		amount:   amount,
+		contract: contract,
+		currency: info.currency,
+		from:     from,
+		to:       to,
+	}, false)
+	return ops, info, nil
+}
+
+// evmPayloads returns the unsigned EVM transaction for the construct options,
+// along with the payload that needs to be signed by the sender.
+func (s *service) evmPayloads(opts *model.ConstructOptions, xfer *transferInfo) (*types.ConstructionPayloadsResponse, *types.Error) {
+	tx, err := s.constructEvmTransfer(opts)
+	if err != nil {
+		return nil, wrapErr(errInvalidConstructOptions, err)
+	}
+	enc, err := rlp.EncodeToBytes(&evmUnsigned{
+		From: ethcom.Address(xfer.from),
+		Tx:   tx,
+	})
+	if err != nil {
+		return nil, wrapErr(errInternal, err)
+	}
+	acct := &types.AccountIdentifier{
+		Address: xfer.from.ToBase58(),
+	}
+	if !xfer.isNative() {
+		acct.SubAccount = contractSubAccount(xfer.currency)
+	}
+	hash := eip155Signer().Hash(tx)
+	return &types.ConstructionPayloadsResponse{
+		Payloads: []*types.SigningPayload{{
+			AccountIdentifier: acct,
+			Bytes:             hash[:],
+			SignatureType:     types.EcdsaRecovery,
+		}},
+		UnsignedTransaction: hex.EncodeToString(enc),
+	}, nil
+}
+
+func (s *service) getContract(md map[string]interface{}) (string, *types.Error) {
+	if md == nil {
+		return "", nil
+	}
 	val, ok := md["contract"]
 	if !ok {
 		return "", nil
@@ -561,60 +971,330 @@ func (s *service) getContract(md map[string]interface{}) (string, *types.Error)
 			),
 		)
 	}
-	addr, err := common.AddressFromHexString(raw)
+	addr, err := parseContract(raw)
 	if err != nil {
 		return "", wrapErr(
 			errInvalidContractAddress,
 			fmt.Errorf("services: unable to parse metadata.contract: %s", err),
 		)
 	}
-	_, xerr := s.store.getCurrencyInfo(addr)
+	info, xerr := s.store.getCurrencyInfo(addr)
 	if xerr != nil {
 		return "", xerr
 	}
-	return addr.ToHexString(), nil
+	return contractSubAccount(info.currency).Address, nil
 }
 
-func (s *service) parsePayload(p ctypes.Payload) ([]*types.Operation, *currencyInfo, *types.Error) {
+// getMaxFee validates the max_fee field of a /construction/preprocess request,
+// which must be a single non-negative amount of ONG.
+func (s *service) getMaxFee(amounts []*types.Amount) ([]byte, *types.Error) {
+	if len(amounts) == 0 {
+		return nil, nil
+	}
+	if len(amounts) > 1 {
+		return nil, wrapErr(
+			errInvalidMaxFee,
+			fmt.Errorf("services: max_fee must only have a single amount of ONG"),
+		)
+	}
+	amt := amounts[0]
+	if amt == nil {
+		return nil, wrapErr(errInvalidMaxFee, fmt.Errorf("services: missing max_fee amount"))
+	}
+	info, xerr := s.store.validateCurrency(amt.Currency)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if info.contract != ongAddr {
+		return nil, wrapErr(
+			errInvalidMaxFee,
+			fmt.Errorf("services: max_fee must be in ONG, not %s", info.currency.Symbol),
+		)
+	}
+	fee, ok := (&big.Int{}).SetString(amt.Value, 10)
+	if !ok || fee.Sign() < 0 {
+		return nil, wrapErr(
+			errInvalidMaxFee,
+			fmt.Errorf("services: invalid max_fee value: %q", amt.Value),
+		)
+	}
+	return fee.Bytes(), nil
+}
+
+// parseEvmTransaction implements /construction/parse for EVM transactions.
+func (s *service) parseEvmTransaction(tx *ethtypes.Transaction, from common.Address, signed bool) (*types.ConstructionParseResponse, *types.Error) {
+	ops, cinfo, xerr := s.evmOperations(tx, from)
+	if xerr != nil {
+		return nil, xerr
+	}
+	var signers []*types.AccountIdentifier
+	if signed {
+		acct := &types.AccountIdentifier{
+			Address: from.ToBase58(),
+		}
+		if !cinfo.isNative() {
+			acct.SubAccount = contractSubAccount(cinfo.currency)
+		}
+		signers = append(signers, acct)
+	}
+	return &types.ConstructionParseResponse{
+		AccountIdentifierSigners: signers,
+		Metadata: map[string]interface{}{
+			"gas_limit": tx.Gas(),
+			"gas_price": (&big.Int{}).Div(tx.GasPrice(), big.NewInt(constants.GWei)).Uint64(),
+			"nonce":     tx.Nonce(),
+			"payer":     from.ToBase58(),
+		},
+		Operations: ops,
+	}, nil
+}
+
+func (s *service) parsePayload(txn *ctypes.Transaction) ([]*types.Operation, *currencyInfo, *types.Error) {
+	p := txn.Payload
 	if p == nil {
 		return nil, nil, errInvalidTransactionPayload
 	}
+	// NOTE(tav): The payloads of EVM transactions in the mempool are always
+	// signed, so the sender can be recovered from them.
+	if evm, ok := p.(*payload.EIP155Code); ok {
+		from, err := ethtypes.Sender(ethtypes.NewEIP155Signer(evm.EIPTx.ChainId()), evm.EIPTx)
+		if err != nil {
+			return nil, nil, wrapErr(errInvalidTransactionPayload, err)
+		}
+		return s.evmOperations(evm.EIPTx, common.Address(from))
+	}
 	invoke, ok := p.(*payload.InvokeCode)
 	if !ok || invoke == nil {
 		return nil, nil, errInvalidTransactionPayload
 	}
-	xfers, contract, err := chain.ParsePayload(invoke.Code)
+	var (
+		inv *chain.Invoke
+		err error
+	)
+	if txn.TxType == ctypes.InvokeWasm {
+		inv, err = chain.ParseWasmInvoke(invoke.Code)
+	} else {
+		inv, err = chain.ParseInvoke(invoke.Code)
+	}
 	if err != nil {
 		return nil, nil, wrapErr(errInvalidTransactionPayload, err)
 	}
+	stake, err := decodeStakingInvoke(inv)
+	if err != nil {
+		return nil, nil, wrapErr(errInvalidTransactionPayload, err)
+	}
+	if stake != nil {
+		return s.stakingOperations(stake)
+	}
+	// NOTE(tav): Transactions may have been created by third parties, so any
+	// method call that isn't a transfer or approval of a known currency is
+	// returned as a generic contract call.
+	info, xerr := s.store.getCurrencyInfo(inv.Contract)
+	if xerr != nil || inv.IsCall() {
+		return []*types.Operation{{
+			Metadata: map[string]interface{}{
+				"contract": inv.Contract.ToHexString(),
+				"method":   inv.Method,
+				"params":   inv.Params,
+			},
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: opContractCall,
+		}}, nil, nil
+	}
+	ops := []*types.Operation{}
+	for _, xfer := range inv.Transfers {
+		if inv.IsApprove() {
+			ops = s.appendOperations(ops, &transferInfo{
+				amount:   xfer.Amount,
+				approve:  true,
+				contract: inv.Contract,
+				currency: info.currency,
+				from:     xfer.From,
+				spender:  xfer.To,
+			}, false)
+			continue
+		}
+		ops = s.appendOperations(ops, &transferInfo{
+			amount:   xfer.Amount,
+			contract: inv.Contract,
+			currency: info.currency,
+			from:     xfer.From,
+			spender:  xfer.Payer,
+			to:       xfer.To,
+		}, false)
+	}
+	return ops, info, nil
+}
+
+// setGasLimit pre-executes the transaction for the given construct options,
+// and either sets the gas limit from the gas consumed plus the configured
+// margin, or checks that the caller-specified gas limit is sufficient.
+func (s *service) setGasLimit(opts *model.ConstructOptions, estimate bool) *types.Error {
+	txn, err := s.constructTransfer(opts)
+	if err != nil {
+		return wrapErr(errInvalidConstructOptions, err)
+	}
+	res, err := chain.PreExecute(txn, constructSigners(opts))
+	if err != nil {
+		return wrapErr(errPreExecutionFailed, err)
+	}
+	// NOTE(tav): NeoVM and WASM contracts may signal a failed transfer, e.g.
+	// due to an insufficient balance, by returning false instead of throwing.
+	ok, err := chain.DecodeBoolResult(res, "transaction", txn.TxType == ctypes.InvokeWasm)
+	if err != nil {
+		return wrapErr(errPreExecutionFailed, err)
+	}
+	if !ok {
+		return wrapErr(
+			errPreExecutionFailed,
+			fmt.Errorf("services: transaction returned false when pre-executed"),
+		)
+	}
+	if !estimate {
+		if opts.GasLimit < res.Gas {
+			return wrapErr(
+				errInvalidGasLimit,
+				fmt.Errorf(
+					"services: gas limit of %d is below the %d gas consumed when pre-executed",
+					opts.GasLimit, res.Gas,
+				),
+			)
+		}
+		return nil
+	}
+	gasLimit := res.Gas + res.Gas*s.gasMargin/100
+	if gasLimit > opts.GasLimit {
+		opts.GasLimit = gasLimit
+	}
+	return nil
+}
+
+// stakingOperations returns the operations for a governance staking method
+// call, along with the currency info for the ONT/ONG it moves.
+func (s *service) stakingOperations(stake *stakingInvoke) ([]*types.Operation, *currencyInfo, *types.Error) {
+	contract := ontAddr
+	if stake.typ == opWithdrawFee {
+		contract = ongAddr
+	}
 	info, xerr := s.store.getCurrencyInfo(contract)
 	if xerr != nil {
 		return nil, nil, xerr
 	}
 	ops := []*types.Operation{}
-	for _, xfer := range xfers {
+	if stake.typ == opWithdrawFee {
 		ops = s.appendOperations(ops, &transferInfo{
-			amount:   xfer.Amount,
 			contract: contract,
 			currency: info.currency,
-			from:     xfer.From,
-			to:       xfer.To,
+			from:     stake.acct,
+			staking:  stake.typ,
+			to:       stake.acct,
+		}, false)
+		return ops, info, nil
+	}
+	for i, peer := range stake.peers {
+		ops = s.appendOperations(ops, &transferInfo{
+			amount:   stake.amounts[i],
+			contract: contract,
+			currency: info.currency,
+			from:     stake.acct,
+			peer:     peer,
+			staking:  stake.typ,
+			to:       stake.acct,
 		}, false)
 	}
 	return ops, info, nil
 }
 
-// NOTE(tav): We currently only support a simple transfer of an asset from one
-// account to another.
-func (s *service) validateOps(ops []*types.Operation) (*transferInfo, *types.Error) {
+// validateApprove validates an approve operation, which sets the allowance
+// that a spender can transfer from an account.
+func (s *service) validateApprove(op *types.Operation) (*transferInfo, *types.Error) {
+	if op.Account == nil {
+		return nil, invalidOpsf("missing operations[0].account")
+	}
+	from, err := common.AddressFromBase58(op.Account.Address)
+	if err != nil {
+		return nil, invalidOpsf("unable to parse operations[0].account.address: %s", err)
+	}
+	if op.Amount != nil {
+		return nil, invalidOpsf(
+			"operations[0].amount cannot be set for approve operations, use metadata.allowance instead",
+		)
+	}
+	if op.OperationIdentifier == nil {
+		return nil, invalidOpsf("missing operations[0].operation_identifier")
+	}
+	if len(op.RelatedOperations) > 0 {
+		return nil, invalidOpsf("unexpected operations[0].related_operations")
+	}
+	spender, xerr := getSpender(op.Metadata, 0)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if spender == common.ADDRESS_EMPTY {
+		return nil, invalidOpsf("missing operations[0].metadata.spender")
+	}
+	raw, ok := op.Metadata["allowance"].(string)
+	if !ok {
+		return nil, invalidOpsf("missing or invalid operations[0].metadata.allowance")
+	}
+	amount, ok := (&big.Int{}).SetString(raw, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, invalidOpsf("invalid operations[0].metadata.allowance: %s", raw)
+	}
+	enc, err := json.Marshal(op.Metadata["currency"])
+	if err != nil {
+		return nil, invalidOpsf("invalid operations[0].metadata.currency: %s", err)
+	}
+	currency := &types.Currency{}
+	if err := json.Unmarshal(enc, currency); err != nil {
+		return nil, invalidOpsf("unable to decode operations[0].metadata.currency: %s", err)
+	}
+	cinfo, xerr := s.store.validateCurrency(currency)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if xerr := validateSubAccount(op, 0, cinfo); xerr != nil {
+		return nil, xerr
+	}
+	return &transferInfo{
+		amount:   amount,
+		approve:  true,
+		contract: cinfo.contract,
+		currency: cinfo.currency,
+		from:     from,
+		spender:  spender,
+	}, nil
+}
+
+// NOTE(tav): We currently support transfers of an asset from one account to
+// one or more accounts, transfers by a spender from an account that has
+// approved an allowance for them, approvals of such allowances, and the
+// staking operations. Each transfer is specified by a pair of operations, one
+// for the sender and the other for the recipient.
+func (s *service) validateOps(ops []*types.Operation) ([]*transferInfo, *types.Error) {
 	if ops == nil {
 		return nil, invalidOpsf("missing operations field")
 	}
-	if len(ops) != 2 {
+	if len(ops) > 0 {
+		switch ops[0].Type {
+		case opStake, opUnstake, opWithdraw, opWithdrawFee:
+			return s.validateStakingOps(ops)
+		}
+	}
+	if len(ops) == 1 && ops[0].Type == opApprove {
+		xfer, xerr := s.validateApprove(ops[0])
+		if xerr != nil {
+			return nil, xerr
+		}
+		return []*transferInfo{xfer}, nil
+	}
+	if len(ops) == 0 || len(ops)%2 != 0 {
 		return nil, invalidOpsf("unexpected number of operations: %d", len(ops))
 	}
-	addrs := make([]common.Address, 2)
-	amounts := make([]*big.Int, 2)
+	addrs := make([]common.Address, len(ops))
+	amounts := make([]*big.Int, len(ops))
 	zero := big.NewInt(0)
 	var cinfo *currencyInfo
 	for i, op := range ops {
@@ -647,80 +1327,491 @@ func (s *service) validateOps(ops []*types.Operation) (*transferInfo, *types.Err
 		if xerr != nil {
 			return nil, xerr
 		}
-		if token.isNative() {
-			if op.Account.SubAccount != nil {
+		if xerr := validateSubAccount(op, i, token); xerr != nil {
+			return nil, xerr
+		}
+		if cinfo == nil {
+			cinfo = token
+		} else if cinfo != token {
+			return nil, invalidOpsf("operations must be in the same currency")
+		}
+		if op.OperationIdentifier == nil {
+			return nil, invalidOpsf("missing operations[%d].operation_identifier", i)
+		}
+		switch op.Type {
+		case opClaimONG, opTransfer, opTransferFrom:
+		default:
+			return nil, invalidOpsf("unsupported operation type: %q", op.Type)
+		}
+		if op.Type != ops[0].Type {
+			return nil, invalidOpsf("operations must all be of the same type")
+		}
+	}
+	if len(ops) > 2 {
+		if cinfo.wasm {
+			return nil, invalidOpsf("batch transfers are not supported for WASM contracts")
+		}
+		if ops[0].Type != opTransfer {
+			return nil, invalidOpsf("batch transfers are not supported for %s operations", ops[0].Type)
+		}
+	}
+	if ops[0].Type == opClaimONG && cinfo.contract != ongAddr {
+		return nil, invalidCurrencyf("claim_ong operations must be in ONG")
+	}
+	xfers := []*transferInfo{}
+	for i := 0; i < len(ops); i += 2 {
+		switch {
+		case len(ops[i].RelatedOperations) > 0:
+			xerr := validateRelation(ops, i, i+1)
+			if xerr != nil {
+				return nil, xerr
+			}
+		case len(ops[i+1].RelatedOperations) > 0:
+			xerr := validateRelation(ops, i+1, i)
+			if xerr != nil {
+				return nil, xerr
+			}
+		default:
+			return nil, invalidOpsf(
+				"invalid related_operations on operations[%d] and operations[%d]",
+				i, i+1,
+			)
+		}
+		sum := (&big.Int{}).Add(amounts[i], amounts[i+1])
+		if sum.Cmp(zero) != 0 {
+			return nil, invalidOpsf(
+				"amount values in operations[%d] and operations[%d] do not sum to zero",
+				i, i+1,
+			)
+		}
+		xfer := &transferInfo{
+			contract: cinfo.contract,
+			currency: cinfo.currency,
+		}
+		debit := i
+		if amounts[i].Sign() > 0 {
+			debit = i + 1
+			xfer.amount = amounts[i]
+			xfer.from = addrs[i+1]
+			xfer.to = addrs[i]
+		} else {
+			xfer.amount = amounts[i+1]
+			xfer.from = addrs[i]
+			xfer.to = addrs[i+1]
+		}
+		if xfer.from == common.ADDRESS_EMPTY {
+			return nil, invalidOpsf("transfers from null addresses are not supported")
+		}
+		// NOTE(tav): Unbound ONG is claimed by the credited account spending
+		// the ONG allowance that the ONT contract grants it.
+		if ops[i].Type == opClaimONG {
+			if xfer.from != ontAddr {
 				return nil, invalidOpsf(
-					"operations[%d].account.sub_account specified for native token", i,
+					"claim_ong operations[%d] must debit the ONT contract address %s",
+					debit, ontAddr.ToBase58(),
 				)
 			}
-		} else {
-			if op.Account.SubAccount == nil {
-				return nil, invalidOpsf("missing operations[%d].account.sub_account", i)
+			xfer.spender = xfer.to
+		} else if xfer.isClaim() {
+			return nil, invalidOpsf("ONG transfers from the ONT contract address must use claim_ong")
+		}
+		if len(xfers) > 0 && xfer.from != xfers[0].from {
+			return nil, invalidOpsf("transfers must all be from the same account")
+		}
+		if ops[i].Type == opTransferFrom {
+			spender, xerr := getSpender(ops[debit].Metadata, debit)
+			if xerr != nil {
+				return nil, xerr
 			}
-			caddr, err := common.AddressFromHexString(op.Account.SubAccount.Address)
-			if err != nil {
+			if spender == common.ADDRESS_EMPTY {
+				return nil, invalidOpsf("missing operations[%d].metadata.spender", debit)
+			}
+			xfer.spender = spender
+		}
+		xfers = append(xfers, xfer)
+	}
+	return xfers, nil
+}
+
+// validateStakingOps validates the operations for the governance staking
+// methods. Staking and withdrawals are specified by pairs of operations that
+// move ONT between an account and its staking sub-account for a consensus
+// node. Unstaking and fee withdrawals are specified by single operations that
+// don't have an amount.
+func (s *service) validateStakingOps(ops []*types.Operation) ([]*transferInfo, *types.Error) {
+	typ := ops[0].Type
+	addrs := make([]common.Address, len(ops))
+	for i, op := range ops {
+		if op.Type != typ {
+			return nil, invalidOpsf("operations must all be of the same type")
+		}
+		if op.OperationIdentifier == nil {
+			return nil, invalidOpsf("missing operations[%d].operation_identifier", i)
+		}
+		if op.Account == nil {
+			return nil, invalidOpsf("missing operations[%d].account", i)
+		}
+		addr, err := common.AddressFromBase58(op.Account.Address)
+		if err != nil {
+			return nil, invalidOpsf(
+				"unable to parse operations[%d].account.address: %s",
+				i, err,
+			)
+		}
+		if i > 0 && addr != addrs[0] {
+			return nil, invalidOpsf("%s operations must all be for the same account", typ)
+		}
+		addrs[i] = addr
+	}
+	acct := addrs[0]
+	if typ == opWithdrawFee {
+		if len(ops) != 1 {
+			return nil, invalidOpsf("unexpected number of withdraw_fee operations: %d", len(ops))
+		}
+		if ops[0].Amount != nil || ops[0].Account.SubAccount != nil {
+			return nil, invalidOpsf("withdraw_fee operations cannot specify an amount or sub_account")
+		}
+		cinfo, xerr := s.store.getCurrencyInfo(ongAddr)
+		if xerr != nil {
+			return nil, xerr
+		}
+		return []*transferInfo{{
+			contract: cinfo.contract,
+			currency: cinfo.currency,
+			from:     acct,
+			staking:  typ,
+			to:       acct,
+		}}, nil
+	}
+	cinfo, xerr := s.store.getCurrencyInfo(ontAddr)
+	if xerr != nil {
+		return nil, xerr
+	}
+	xfers := []*transferInfo{}
+	if typ == opUnstake {
+		for i, op := range ops {
+			if op.Amount != nil {
 				return nil, invalidOpsf(
-					"unable to parse operations[%d].account.sub_account.address: %s",
-					i, err,
+					"operations[%d].amount cannot be set for unstake operations, use metadata.amount instead",
+					i,
 				)
 			}
-			if token.contract != caddr {
+			if len(op.RelatedOperations) > 0 {
+				return nil, invalidOpsf("unexpected operations[%d].related_operations", i)
+			}
+			peer, xerr := getStakingPeer(op, i)
+			if xerr != nil {
+				return nil, xerr
+			}
+			if peer == nil {
+				return nil, invalidOpsf("operations[%d].account must be a staking sub_account", i)
+			}
+			raw, ok := op.Metadata["amount"].(string)
+			if !ok {
+				return nil, invalidOpsf("missing or invalid operations[%d].metadata.amount", i)
+			}
+			amount, ok := (&big.Int{}).SetString(raw, 10)
+			if !ok {
+				return nil, invalidOpsf("invalid operations[%d].metadata.amount: %s", i, raw)
+			}
+			if xerr := validateStakeAmount(amount, i); xerr != nil {
+				return nil, xerr
+			}
+			xfers = append(xfers, &transferInfo{
+				amount:   amount,
+				contract: cinfo.contract,
+				currency: cinfo.currency,
+				from:     acct,
+				peer:     peer,
+				staking:  typ,
+				to:       acct,
+			})
+		}
+		return xfers, nil
+	}
+	if len(ops)%2 != 0 {
+		return nil, invalidOpsf("unexpected number of operations: %d", len(ops))
+	}
+	for i := 0; i < len(ops); i += 2 {
+		switch {
+		case len(ops[i].RelatedOperations) > 0:
+			if xerr := validateRelation(ops, i, i+1); xerr != nil {
+				return nil, xerr
+			}
+		case len(ops[i+1].RelatedOperations) > 0:
+			if xerr := validateRelation(ops, i+1, i); xerr != nil {
+				return nil, xerr
+			}
+		default:
+			return nil, invalidOpsf(
+				"invalid related_operations on operations[%d] and operations[%d]",
+				i, i+1,
+			)
+		}
+		var (
+			amounts [2]*big.Int
+			peer    []byte
+			staked  int
+		)
+		for j := 0; j < 2; j++ {
+			op := ops[i+j]
+			if op.Amount == nil {
+				return nil, invalidOpsf("missing operations[%d].amount", i+j)
+			}
+			token, xerr := s.store.validateCurrency(op.Amount.Currency)
+			if xerr != nil {
+				return nil, xerr
+			}
+			if token != cinfo {
+				return nil, invalidCurrencyf("%s operations must be in ONT", typ)
+			}
+			amount, ok := (&big.Int{}).SetString(op.Amount.Value, 10)
+			if !ok {
 				return nil, invalidOpsf(
-					"operations[%d].account.sub_account.address does not match currency",
-					i,
+					"invalid operations[%d].amount.value: %s",
+					i+j, op.Amount.Value,
 				)
 			}
+			amounts[j] = amount
+			opPeer, xerr := getStakingPeer(op, i+j)
+			if xerr != nil {
+				return nil, xerr
+			}
+			if opPeer != nil {
+				if peer != nil {
+					return nil, invalidOpsf(
+						"only one of operations[%d] and operations[%d] can be a staking sub_account",
+						i, i+1,
+					)
+				}
+				peer = opPeer
+				staked = j
+			}
 		}
-		if cinfo == nil {
-			cinfo = token
-		} else if cinfo != token {
-			return nil, invalidOpsf("operations must be in the same currency")
+		if peer == nil {
+			return nil, invalidOpsf(
+				"one of operations[%d] and operations[%d] must be a staking sub_account",
+				i, i+1,
+			)
 		}
-		if op.OperationIdentifier == nil {
-			return nil, invalidOpsf("missing operations[%d].operation_identifier", i)
+		if (&big.Int{}).Add(amounts[0], amounts[1]).Sign() != 0 {
+			return nil, invalidOpsf(
+				"amount values in operations[%d] and operations[%d] do not sum to zero",
+				i, i+1,
+			)
 		}
-		if op.Type != opTransfer {
-			return nil, invalidOpsf("unsupported operation type: %q", op.Type)
+		// NOTE(tav): Staking moves ONT into the staking sub-account, while
+		// withdrawals move it back out.
+		amount := amounts[staked]
+		if typ == opWithdraw {
+			amount = (&big.Int{}).Neg(amount)
 		}
+		if amount.Sign() <= 0 {
+			return nil, invalidOpsf(
+				"operations[%d].amount.value has the wrong sign for %s operations",
+				i+staked, typ,
+			)
+		}
+		if xerr := validateStakeAmount(amount, i+staked); xerr != nil {
+			return nil, xerr
+		}
+		xfers = append(xfers, &transferInfo{
+			amount:   amount,
+			contract: cinfo.contract,
+			currency: cinfo.currency,
+			from:     acct,
+			peer:     peer,
+			staking:  typ,
+			to:       acct,
+		})
+	}
+	return xfers, nil
+}
+
+// verifyToken checks the contract of the OEP4 token being transferred, so that
+// changes to it since startup are caught before a transaction is constructed.
+func (s *service) verifyToken(opts *model.ConstructOptions) *types.Error {
+	contract, err := common.AddressParseFromBytes(opts.Contract)
+	if err != nil {
+		return wrapErr(errInvalidContractAddress, err)
+	}
+	info, xerr := s.store.getCurrencyInfo(contract)
+	if xerr != nil {
+		return xerr
+	}
+	if info.isNative() || info.evm || opts.Staking != "" {
+		return nil
+	}
+	err = VerifyOEP4Token(&OEP4Token{
+		Contract: contract,
+		Decimals: info.currency.Decimals,
+		Symbol:   info.currency.Symbol,
+		Wasm:     info.wasm,
+	})
+	if err != nil {
+		return wrapErr(errTokenVerification, err)
+	}
+	return nil
+}
+
+// checkMaxFee returns the fee for the gas limit and price in the construct
+// options, denominated in the 18 decimal units of ONG, and errors if it exceeds
+// any max fee specified by the caller.
+func checkMaxFee(opts *model.ConstructOptions) (*big.Int, *types.Error) {
+	fee := (&big.Int{}).SetUint64(opts.GasLimit)
+	fee.Mul(fee, (&big.Int{}).SetUint64(opts.GasPrice))
+	fee.Mul(fee, big.NewInt(constants.GWei))
+	if len(opts.MaxFee) == 0 {
+		return fee, nil
+	}
+	maxFee := (&big.Int{}).SetBytes(opts.MaxFee)
+	if fee.Cmp(maxFee) > 0 {
+		return nil, wrapErr(
+			errMaxFeeExceeded,
+			fmt.Errorf(
+				"services: fee of %s for gas limit %d and gas price %d exceeds the max fee of %s",
+				fee, opts.GasLimit, opts.GasPrice, maxFee,
+			),
+		)
+	}
+	return fee, nil
+}
+
+// combineEvmTransaction implements /construction/combine for EVM
+// transactions, which are signed with a recoverable secp256k1 signature by the
+// sender.
+func combineEvmTransaction(utx *evmUnsigned, sigs []*types.Signature) (*types.ConstructionCombineResponse, *types.Error) {
+	if len(sigs) != 1 {
+		return nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf("services: EVM transactions need exactly 1 signature, got %d", len(sigs)),
+		)
 	}
-	switch {
-	case len(ops[0].RelatedOperations) > 0:
-		xerr := validateRelation(ops, 0, 1)
-		if xerr != nil {
-			return nil, xerr
-		}
-	case len(ops[1].RelatedOperations) > 0:
-		xerr := validateRelation(ops, 1, 0)
-		if xerr != nil {
-			return nil, xerr
+	sig := sigs[0]
+	key, xerr := parsePublicKey(sig.PublicKey)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if _, ok := key.(*ec.EthereumPublicKey); !ok {
+		return nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf("services: EVM transactions must be signed with a secp256k1 key"),
+		)
+	}
+	if sig.SignatureType != types.EcdsaRecovery {
+		return nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf(
+				"services: unsupported signature type for EVM transaction: %q",
+				sig.SignatureType,
+			),
+		)
+	}
+	signer := eip155Signer()
+	hash := signer.Hash(utx.Tx)
+	if sig.SigningPayload == nil || !bytes.Equal(sig.SigningPayload.Bytes, hash[:]) {
+		return nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf(
+				"services: mismatching signing_payload.hex_bytes and transaction hash",
+			),
+		)
+	}
+	if len(sig.Bytes) != ethcrypto.SignatureLength {
+		return nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf(
+				"services: invalid length for an ecdsa_recovery signature: %d",
+				len(sig.Bytes),
+			),
+		)
+	}
+	pub, err := ethcrypto.SigToPub(hash[:], sig.Bytes)
+	if err != nil {
+		return nil, wrapErr(errInvalidSignature, err)
+	}
+	if ethcrypto.PubkeyToAddress(*pub) != utx.From || ctypes.AddressFromPubKey(key) != common.Address(utx.From) {
+		return nil, errInvalidSignature
+	}
+	tx, err := utx.Tx.WithSignature(signer, sig.Bytes)
+	if err != nil {
+		return nil, wrapErr(errInvalidSignature, err)
+	}
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, wrapErr(errInternal, err)
+	}
+	return &types.ConstructionCombineResponse{
+		SignedTransaction: hex.EncodeToString(enc),
+	}, nil
+}
+
+// constructSigners returns the accounts that need to sign the transaction for
+// the given construct options.
+func constructSigners(opts *model.ConstructOptions) []common.Address {
+	signer := opts.From
+	if len(opts.Spender) > 0 && !opts.Approve {
+		signer = opts.Spender
+	}
+	signers := []common.Address{}
+	for _, raw := range [][]byte{signer, opts.Payer} {
+		addr, err := common.AddressParseFromBytes(raw)
+		if err != nil {
+			continue
 		}
-	default:
-		return nil, invalidOpsf("invalid related_operations on operations")
+		signers = append(signers, addr)
+	}
+	return signers
+}
+
+// decodeEvmTransaction decodes a signed EVM transaction from its RLP encoding,
+// and converts it into the node's representation.
+func decodeEvmTransaction(raw []byte) (*ctypes.Transaction, error) {
+	tx := &ethtypes.Transaction{}
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return nil, err
 	}
-	sum := (&big.Int{}).Add(amounts[0], amounts[1])
-	if sum.Cmp(zero) != 0 {
-		return nil, invalidOpsf("amount values in operations do not sum to zero")
+	if chainID := evmChainID(); tx.ChainId().Cmp(chainID) != 0 {
+		return nil, fmt.Errorf(
+			"services: invalid EVM chain id: got %s, want %s",
+			tx.ChainId(), chainID,
+		)
 	}
-	xfer := &transferInfo{
-		contract: cinfo.contract,
-		currency: cinfo.currency,
+	return ctypes.TransactionFromEIP155(tx)
+}
+
+// decodeEvmUnsigned decodes an unsigned EVM transaction, as returned by
+// /construction/payloads. It returns nil if the data isn't RLP-encoded.
+func decodeEvmUnsigned(data string) (*evmUnsigned, *types.Error) {
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, wrapErr(errInvalidTransactionPayload, err)
 	}
-	switch amounts[0].Cmp(zero) {
-	case 1:
-		xfer.amount = amounts[0]
-		xfer.from = addrs[1]
-		xfer.to = addrs[0]
-	case -1:
-		xfer.amount = amounts[1]
-		xfer.from = addrs[0]
-		xfer.to = addrs[1]
-	default:
-		return nil, invalidOpsf("amount values in operations cannot be zero")
+	if !isRLPList(raw) {
+		return nil, nil
+	}
+	utx := &evmUnsigned{}
+	if err := rlp.DecodeBytes(raw, utx); err != nil {
+		return nil, wrapErr(errInvalidTransactionPayload, err)
 	}
-	if xfer.from == common.ADDRESS_EMPTY {
-		return nil, invalidOpsf("transfers from null addresses are not supported")
+	return utx, nil
+}
+
+// decodeMultisig returns the multi-sig account specified in the construct
+// options, if any.
+func decodeMultisig(opts *model.ConstructOptions) (*multisig, error) {
+	if len(opts.MultisigKeys) == 0 {
+		return nil, nil
+	}
+	keys := make([]keypair.PublicKey, len(opts.MultisigKeys))
+	for i, raw := range opts.MultisigKeys {
+		key, err := keypair.DeserializePublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("services: unable to decode multi-sig public key: %s", err)
+		}
+		keys[i] = key
 	}
-	return xfer, nil
+	return newMultisig(keys, uint64(opts.MultisigM))
 }
 
 func decodeProtobuf(md map[string]interface{}, m proto.Message) *types.Error {
@@ -750,7 +1841,14 @@ func decodeTransaction(data string) (*ctypes.Transaction, *types.Error) {
 	if err != nil {
 		return nil, wrapErr(errInvalidTransactionPayload, err)
 	}
-	txn, err := ctypes.TransactionFromRawBytes(raw)
+	// NOTE(tav): Signed EVM transactions use the same RLP encoding as on
+	// Ethereum, while the node's own encoding starts with a zero version byte.
+	var txn *ctypes.Transaction
+	if isRLPList(raw) {
+		txn, err = decodeEvmTransaction(raw)
+	} else {
+		txn, err = ctypes.TransactionFromRawBytes(raw)
+	}
 	if err != nil {
 		return nil, wrapErr(errInvalidTransactionPayload, err)
 	}
@@ -763,6 +1861,54 @@ func decodeTransaction(data string) (*ctypes.Transaction, *types.Error) {
 	return txn, nil
 }
 
+func eip155Signer() ethtypes.EIP155Signer {
+	return ethtypes.NewEIP155Signer(evmChainID())
+}
+
+// encodeTransfers sets the fields in the construct options that are derived
+// from the operations.
+func encodeTransfers(opts *model.ConstructOptions, xfers []*transferInfo) {
+	xfer := xfers[0]
+	opts.Contract = xfer.contract[:]
+	opts.From = xfer.from[:]
+	if xfer.spender != common.ADDRESS_EMPTY {
+		opts.Approve = xfer.approve
+		opts.Spender = xfer.spender[:]
+	}
+	switch {
+	case xfer.staking != "":
+		opts.Staking = xfer.staking
+		for _, xfer := range xfers {
+			if xfer.peer == nil {
+				continue
+			}
+			opts.Recipients = append(opts.Recipients, &model.Recipient{
+				Amount: xfer.amount.Bytes(),
+				Peer:   xfer.peer,
+			})
+		}
+	case xfer.approve:
+		opts.Amount = xfer.amount.Bytes()
+	case len(xfers) == 1:
+		// NOTE(tav): Single transfers continue to use the amount and to fields,
+		// so that their options remain the same as before batches were
+		// supported.
+		opts.Amount = xfer.amount.Bytes()
+		opts.To = xfer.to[:]
+	default:
+		for _, xfer := range xfers {
+			opts.Recipients = append(opts.Recipients, &model.Recipient{
+				Amount: xfer.amount.Bytes(),
+				To:     xfer.to[:],
+			})
+		}
+	}
+}
+
+func evmChainID() *big.Int {
+	return big.NewInt(int64(config.DefConfig.P2PNode.EVMChainId))
+}
+
 func getGasPrice() (uint64, error) {
 	var end uint32 = 0
 	var price uint64 = 0
@@ -788,6 +1934,52 @@ func getGasPrice() (uint64, error) {
 	return price, nil
 }
 
+// getMultisig decodes an M-of-N multi-sig account from the m and public_keys
+// metadata fields. It returns nil if public_keys has not been specified.
+func getMultisig(md map[string]interface{}) (*multisig, *types.Error) {
+	if md == nil {
+		return nil, nil
+	}
+	val, ok := md["public_keys"]
+	if !ok {
+		return nil, nil
+	}
+	enc, err := json.Marshal(val)
+	if err != nil {
+		return nil, wrapErr(errInvalidPublicKey, err)
+	}
+	var pks []*types.PublicKey
+	if err := json.Unmarshal(enc, &pks); err != nil {
+		return nil, wrapErr(
+			errInvalidPublicKey,
+			fmt.Errorf("services: unable to decode metadata.public_keys: %s", err),
+		)
+	}
+	keys := make([]keypair.PublicKey, len(pks))
+	for i, pk := range pks {
+		key, xerr := parsePublicKey(pk)
+		if xerr != nil {
+			return nil, xerr
+		}
+		if _, ok := key.(*ec.EthereumPublicKey); ok {
+			return nil, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf("services: secp256k1 keys cannot be used for multi-sig accounts"),
+			)
+		}
+		keys[i] = key
+	}
+	m, err := getUint64Field(md, "m")
+	if err != nil {
+		return nil, wrapErr(errInvalidPublicKey, err)
+	}
+	multi, err := newMultisig(keys, m)
+	if err != nil {
+		return nil, wrapErr(errInvalidPublicKey, err)
+	}
+	return multi, nil
+}
+
 func getPayer(md map[string]interface{}) (common.Address, *types.Error) {
 	if md == nil {
 		return common.ADDRESS_EMPTY, nil
@@ -816,6 +2008,82 @@ func getPayer(md map[string]interface{}) (common.Address, *types.Error) {
 	return addr, nil
 }
 
+// getPeer decodes the public key of the consensus node specified in the
+// metadata of a staking sub-account.
+func getPeer(md map[string]interface{}) ([]byte, error) {
+	val, ok := md["peer_pubkey"]
+	if !ok {
+		return nil, fmt.Errorf("services: missing peer_pubkey field")
+	}
+	raw, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("services: peer_pubkey field is not a string")
+	}
+	peer, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("services: unable to decode peer_pubkey: %s", err)
+	}
+	if len(peer) != peerPubkeySize {
+		return nil, fmt.Errorf("services: invalid peer_pubkey length: %d", len(peer))
+	}
+	return peer, nil
+}
+
+// getStakingPeer returns the consensus node for the operation at the given
+// offset if its account is a staking sub-account, or nil otherwise.
+func getStakingPeer(op *types.Operation, offset int) ([]byte, *types.Error) {
+	sub := op.Account.SubAccount
+	if sub == nil {
+		return nil, nil
+	}
+	if sub.Address != subAcctStaking {
+		return nil, invalidOpsf(
+			"operations[%d].account.sub_account.address must be %q",
+			offset, subAcctStaking,
+		)
+	}
+	peer, err := getPeer(sub.Metadata)
+	if err != nil {
+		return nil, wrapErr(errInvalidOpsIntent, err)
+	}
+	return peer, nil
+}
+
+// getRecipients returns the recipients of the transfers specified in the
+// construct options.
+func getRecipients(opts *model.ConstructOptions) []*model.Recipient {
+	if len(opts.Recipients) > 0 {
+		return opts.Recipients
+	}
+	return []*model.Recipient{{
+		Amount: opts.Amount,
+		To:     opts.To,
+	}}
+}
+
+// getSpender decodes the spender address from the metadata for the operation at
+// the given offset. It returns the empty address if it hasn't been specified.
+func getSpender(md map[string]interface{}, offset int) (common.Address, *types.Error) {
+	val, ok := md["spender"]
+	if !ok {
+		return common.ADDRESS_EMPTY, nil
+	}
+	raw, ok := val.(string)
+	if !ok {
+		return common.ADDRESS_EMPTY, invalidOpsf(
+			"unexpected datatype for operations[%d].metadata.spender: %s",
+			offset, reflect.TypeOf(val),
+		)
+	}
+	addr, err := common.AddressFromBase58(raw)
+	if err != nil {
+		return common.ADDRESS_EMPTY, invalidOpsf(
+			"unable to parse operations[%d].metadata.spender: %s", offset, err,
+		)
+	}
+	return addr, nil
+}
+
 func getUint64Field(md map[string]interface{}, field string) (uint64, error) {
 	if md == nil {
 		return 0, nil
@@ -847,8 +2115,6 @@ func getUint64Field(md map[string]interface{}, field string) (uint64, error) {
 			)
 		}
 		return v, nil
-	case "gas_price":
-		return defaultGasPrice, nil
 	case "nonce":
 		if v > math.MaxUint32 {
 			return 0, fmt.Errorf("services: nonce value %d is outside the uint32 range", v)
@@ -858,6 +2124,188 @@ func getUint64Field(md map[string]interface{}, field string) (uint64, error) {
 	return v, nil
 }
 
+// isEvmTransfer returns whether the transfer in the construct options needs to
+// be made with an EVM transaction, i.e. if the sender has a secp256k1 key.
+func isEvmTransfer(opts *model.ConstructOptions, pks []*types.PublicKey) (bool, *types.Error) {
+	evm := false
+	for _, pk := range pks {
+		key, xerr := parsePublicKey(pk)
+		if xerr != nil {
+			return false, xerr
+		}
+		if _, ok := key.(*ec.EthereumPublicKey); !ok {
+			continue
+		}
+		addr := ctypes.AddressFromPubKey(key)
+		if !bytes.Equal(addr[:], opts.From) || (len(opts.Payer) > 0 && !bytes.Equal(addr[:], opts.Payer)) {
+			return false, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf(
+					"services: secp256k1 keys can only sign EVM transfers from their own account: %s",
+					addr.ToBase58(),
+				),
+			)
+		}
+		evm = true
+	}
+	return evm, nil
+}
+
+// isRLPList returns whether the data is an RLP-encoded list, as used for EVM
+// transactions.
+func isRLPList(data []byte) bool {
+	return len(data) > 0 && data[0] >= 0xc0
+}
+
+// multisigSigners returns the public keys of a multi-sig account that the
+// signatures in the given Sig were made with.
+func multisigSigners(sig ctypes.Sig, hash []byte) ([]keypair.PublicKey, error) {
+	var signers []keypair.PublicKey
+	pos := 0
+	for _, data := range sig.SigData {
+		osig, err := signature.Deserialize(data)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for ; pos < len(sig.PubKeys); pos++ {
+			if signature.Verify(sig.PubKeys[pos], hash, osig) {
+				signers = append(signers, sig.PubKeys[pos])
+				found = true
+				pos++
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("services: signature does not match any remaining multi-sig public key")
+		}
+	}
+	return signers, nil
+}
+
+func newMultisig(keys []keypair.PublicKey, m uint64) (*multisig, error) {
+	if m > math.MaxUint16 {
+		return nil, fmt.Errorf("services: invalid multi-sig m value: %d", m)
+	}
+	addr, err := ctypes.AddressFromMultiPubKeys(keys, int(m))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"services: invalid %d-of-%d multi-sig account: %s", m, len(keys), err,
+		)
+	}
+	return &multisig{
+		addr: addr,
+		keys: keypair.SortPublicKeys(keys),
+		m:    uint16(m),
+	}, nil
+}
+
+func parsePublicKey(pk *types.PublicKey) (keypair.PublicKey, *types.Error) {
+	if pk == nil {
+		return nil, errInvalidPublicKey
+	}
+	switch pk.CurveType {
+	case types.Edwards25519:
+		if len(pk.Bytes) != ed25519.PublicKeySize {
+			return nil, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf(
+					"services: invalid length for an ed25519 key: %d",
+					len(pk.Bytes),
+				),
+			)
+		}
+		return ed25519.PublicKey(pk.Bytes), nil
+	case types.Secp256r1:
+		// NOTE(tav): The node encodes P-256 keys in the same compressed form as
+		// Rosetta, so we can decode them directly.
+		if len(pk.Bytes) != 33 || (pk.Bytes[0] != 0x02 && pk.Bytes[0] != 0x03) {
+			return nil, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf("services: invalid compressed secp256r1 key"),
+			)
+		}
+		key, err := keypair.DeserializePublicKey(pk.Bytes)
+		if err != nil {
+			return nil, wrapErr(errInvalidPublicKey, err)
+		}
+		return key, nil
+	case types.Secp256k1:
+		// NOTE(tav): secp256k1 keys are only supported for EVM transactions,
+		// and their addresses are derived in the same way as on Ethereum.
+		if len(pk.Bytes) != 33 {
+			return nil, wrapErr(
+				errInvalidPublicKey,
+				fmt.Errorf("services: invalid compressed secp256k1 key"),
+			)
+		}
+		key, err := ethcrypto.DecompressPubkey(pk.Bytes)
+		if err != nil {
+			return nil, wrapErr(errInvalidPublicKey, err)
+		}
+		return &ec.EthereumPublicKey{PublicKey: key}, nil
+	default:
+		return nil, wrapErr(
+			errInvalidPublicKey,
+			fmt.Errorf("services: unsupported key type: %s", pk.CurveType),
+		)
+	}
+}
+
+// signingPayload returns the bytes that need to be signed by the given key for
+// a transaction hash, along with the corresponding signature type.
+//
+// For ECDSA keys, the node verifies signatures over the SHA-256 digest of the
+// transaction hash, so the digest is what gets signed.
+func signingPayload(key keypair.PublicKey, hash []byte) ([]byte, types.SignatureType) {
+	if _, ok := key.(*ec.PublicKey); ok {
+		digest := sha256.Sum256(hash)
+		return digest[:], types.Ecdsa
+	}
+	return hash, types.Ed25519
+}
+
+// stakingParams returns the governance contract method and params for the
+// staking operation in the construct options.
+func stakingParams(opts *model.ConstructOptions, from common.Address) (string, []interface{}, error) {
+	if opts.Staking == opWithdrawFee {
+		return "withdrawFee", []interface{}{&struct {
+			Address common.Address
+		}{from}}, nil
+	}
+	var method string
+	switch opts.Staking {
+	case opStake:
+		method = "authorizeForPeer"
+	case opUnstake:
+		method = "unAuthorizeForPeer"
+	case opWithdraw:
+		method = "withdraw"
+	default:
+		return "", nil, fmt.Errorf("services: unknown staking type: %q", opts.Staking)
+	}
+	param := &struct {
+		Address common.Address
+		Peers   []string
+		Amounts []uint32
+	}{Address: from}
+	unit := big.NewInt(constants.GWei)
+	for _, recipient := range opts.Recipients {
+		whole, rem := (&big.Int{}).QuoRem(
+			(&big.Int{}).SetBytes(recipient.Amount), unit, &big.Int{},
+		)
+		if rem.Sign() != 0 || whole.Cmp(big.NewInt(math.MaxUint32)) > 0 {
+			return "", nil, fmt.Errorf("services: invalid staking amount")
+		}
+		param.Peers = append(param.Peers, hex.EncodeToString(recipient.Peer))
+		param.Amounts = append(param.Amounts, uint32(whole.Uint64()))
+	}
+	if len(param.Peers) == 0 {
+		return "", nil, fmt.Errorf("services: missing staking peers")
+	}
+	return method, []interface{}{param}, nil
+}
+
 func txhash2response(hash common.Uint256) (*types.TransactionIdentifierResponse, *types.Error) {
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
@@ -866,6 +2314,47 @@ func txhash2response(hash common.Uint256) (*types.TransactionIdentifierResponse,
 	}, nil
 }
 
+func validateSubAccount(op *types.Operation, offset int, cinfo *currencyInfo) *types.Error {
+	if cinfo.isNative() {
+		if op.Account.SubAccount != nil {
+			return invalidOpsf(
+				"operations[%d].account.sub_account specified for native token", offset,
+			)
+		}
+		return nil
+	}
+	if op.Account.SubAccount == nil {
+		return invalidOpsf("missing operations[%d].account.sub_account", offset)
+	}
+	caddr, err := parseContract(op.Account.SubAccount.Address)
+	if err != nil {
+		return invalidOpsf(
+			"unable to parse operations[%d].account.sub_account.address: %s",
+			offset, err,
+		)
+	}
+	if cinfo.contract != caddr {
+		return invalidOpsf(
+			"operations[%d].account.sub_account.address does not match currency",
+			offset,
+		)
+	}
+	return nil
+}
+
+// validateStakeAmount validates that the amount, in units of 10^-9 ONT, can be
+// staked by the governance contract, which only accepts whole units of ONT.
+func validateStakeAmount(amount *big.Int, offset int) *types.Error {
+	whole, rem := (&big.Int{}).QuoRem(amount, big.NewInt(constants.GWei), &big.Int{})
+	if amount.Sign() <= 0 || rem.Sign() != 0 || whole.Cmp(big.NewInt(math.MaxUint32)) > 0 {
+		return invalidOpsf(
+			"operations[%d] amount must be a positive whole number of ONT: %s",
+			offset, amount,
+		)
+	}
+	return nil
+}
+
 func validateRelation(ops []*types.Operation, ifrom int, ito int) *types.Error {
 	if len(ops[ito].RelatedOperations) > 0 {
 		return invalidOpsf(
@@ -893,3 +2382,75 @@ func validateRelation(ops []*types.Operation, ifrom int, ito int) *types.Error {
 	}
 	return nil
 }
+
+// verifySignature verifies a signature over the given transaction hash, and
+// returns the public key along with the signature in the node's encoding.
+func verifySignature(sig *types.Signature, hash []byte) (keypair.PublicKey, []byte, *types.Error) {
+	key, xerr := parsePublicKey(sig.PublicKey)
+	if xerr != nil {
+		return nil, nil, xerr
+	}
+	if sig.SigningPayload == nil {
+		return nil, nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf("services: signing_payload missing"),
+		)
+	}
+	msg, typ := signingPayload(key, hash)
+	if sig.SignatureType != typ {
+		return nil, nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf(
+				"services: unsupported signature type for %s key: %q",
+				sig.PublicKey.CurveType, sig.SignatureType,
+			),
+		)
+	}
+	if !bytes.Equal(sig.SigningPayload.Bytes, msg) {
+		return nil, nil, wrapErr(
+			errInvalidSignature,
+			fmt.Errorf(
+				"services: mismatching signing_payload.hex_bytes and transaction hash",
+			),
+		)
+	}
+	var osig *signature.Signature
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, msg, sig.Bytes) {
+			return nil, nil, errInvalidSignature
+		}
+		osig = &signature.Signature{
+			Scheme: signature.SHA512withEDDSA,
+			Value:  sig.Bytes,
+		}
+	case *ec.PublicKey:
+		if len(sig.Bytes) != 64 {
+			return nil, nil, wrapErr(
+				errInvalidSignature,
+				fmt.Errorf(
+					"services: invalid length for an ecdsa signature: %d",
+					len(sig.Bytes),
+				),
+			)
+		}
+		r := new(big.Int).SetBytes(sig.Bytes[:32])
+		s := new(big.Int).SetBytes(sig.Bytes[32:])
+		if !ecdsa.Verify(key.PublicKey, msg, r, s) {
+			return nil, nil, errInvalidSignature
+		}
+		osig = &signature.Signature{
+			Scheme: signature.SHA256withECDSA,
+			Value: &signature.DSASignature{
+				Curve: elliptic.P256(),
+				R:     r,
+				S:     s,
+			},
+		}
+	}
+	enc, err := signature.Serialize(osig)
+	if err != nil {
+		return nil, nil, wrapErr(errInvalidSignature, err)
+	}
+	return key, enc, nil
+}
@@ -0,0 +1,827 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/ontio/ontology-rosetta/chain"
+	"github.com/ontio/ontology-rosetta/model"
+	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/core/payload"
+	ctypes "github.com/ontio/ontology/core/types"
+	"github.com/ontio/ontology/core/utils"
+	"github.com/ontio/ontology/core/validation"
+	"github.com/ontio/ontology/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAllowanceConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	owner := testAcct.ToBase58()
+	spender := "AFmseVrdL9f9oyCzZefL9tG6UbvhfRZMHJ"
+	approve := []*types.Operation{{
+		Account: &types.AccountIdentifier{Address: owner},
+		Metadata: testMetadata(t, map[string]interface{}{
+			"allowance": "100",
+			"currency":  testTransferOps(owner, owner, 1)[0].Amount.Currency,
+			"spender":   spender,
+		}),
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opApprove,
+	}}
+	transferFrom := testTransferOps(owner, "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV", 100)
+	for _, op := range transferFrom {
+		op.Type = opTransferFrom
+	}
+	transferFrom[0].Metadata = map[string]interface{}{"spender": spender}
+	claim := testTransferOps(ontAddr.ToBase58(), owner, 100)
+	ong := &types.Currency{
+		Decimals: 18,
+		Metadata: map[string]interface{}{
+			"contract": ongAddr.ToHexString(),
+		},
+		Symbol: "ONG",
+	}
+	for _, op := range claim {
+		op.Amount.Currency = ong
+		op.Type = opClaimONG
+	}
+	for _, tc := range []struct {
+		name   string
+		ops    []*types.Operation
+		signer string
+	}{
+		{"approve", approve, owner},
+		{"transfer_from", transferFrom, spender},
+		{"claim_ong", claim, owner},
+	} {
+		pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+			Operations: tc.ops,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to preprocess %s: %s", tc.name, xerr.Message)
+		}
+		if len(pre.RequiredPublicKeys) != 1 || pre.RequiredPublicKeys[0].Address != tc.signer {
+			t.Fatalf("Unexpected required_public_keys for %s: %v", tc.name, pre.RequiredPublicKeys)
+		}
+		payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Metadata:   pre.Options,
+			Operations: tc.ops,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to get payloads for %s: %s", tc.name, xerr.Message)
+		}
+		if len(payloads.Payloads) != 1 || payloads.Payloads[0].AccountIdentifier.Address != tc.signer {
+			t.Fatalf("Unexpected payloads for %s: %v", tc.name, payloads.Payloads)
+		}
+		parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Transaction: payloads.UnsignedTransaction,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to parse %s: %s", tc.name, xerr.Message)
+		}
+		if len(parsed.Operations) != len(tc.ops) {
+			t.Fatalf("Unexpected number of operations for %s: %d", tc.name, len(parsed.Operations))
+		}
+		for i, op := range parsed.Operations {
+			want := tc.ops[i]
+			if op.Type != want.Type || op.Account.Address != want.Account.Address {
+				t.Fatalf("Unexpected operation at offset %d for %s: %v", i, tc.name, op)
+			}
+			if (op.Amount == nil) != (want.Amount == nil) ||
+				(op.Amount != nil && op.Amount.Value != want.Amount.Value) {
+				t.Fatalf("Unexpected amount at offset %d for %s: %v", i, tc.name, op.Amount)
+			}
+			for _, field := range []string{"allowance", "spender"} {
+				if fmt.Sprint(op.Metadata[field]) != fmt.Sprint(want.Metadata[field]) {
+					t.Fatalf(
+						"Unexpected metadata.%s at offset %d for %s: got %v, want %v",
+						field, i, tc.name, op.Metadata[field], want.Metadata[field],
+					)
+				}
+			}
+		}
+	}
+}
+
+func TestBatchTransferConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	from := testAcct.ToBase58()
+	var ops []*types.Operation
+	for i, to := range []string{
+		"AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV",
+		"AFmseVrdL9f9oyCzZefL9tG6UbvhfRZMHJ",
+		"AFmseVrdL9f9oyCzZefL9tG6UbviEH9ugK",
+	} {
+		base := int64(len(ops))
+		for _, op := range testTransferOps(from, to, int64(100*(i+1))) {
+			op.OperationIdentifier.Index += base
+			for _, rel := range op.RelatedOperations {
+				rel.Index += base
+			}
+			ops = append(ops, op)
+		}
+	}
+	pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to preprocess batch transfer: %s", xerr.Message)
+	}
+	payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Metadata:   pre.Options,
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to get payloads: %s", xerr.Message)
+	}
+	if len(payloads.Payloads) != 1 {
+		t.Fatalf("Unexpected number of payloads: got %d, want 1", len(payloads.Payloads))
+	}
+	parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payloads.UnsignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to parse batch transfer: %s", xerr.Message)
+	}
+	if len(parsed.Operations) != len(ops) {
+		t.Fatalf("Unexpected number of operations: got %d, want %d", len(parsed.Operations), len(ops))
+	}
+	for i, op := range parsed.Operations {
+		if op.Account.Address != ops[i].Account.Address || op.Amount.Value != ops[i].Amount.Value {
+			t.Fatalf(
+				"Unexpected operation at offset %d: got %s %s, want %s %s", i,
+				op.Account.Address, op.Amount.Value,
+				ops[i].Account.Address, ops[i].Amount.Value,
+			)
+		}
+	}
+	ops[4].Account.Address = "AFmseVrdL9f9oyCzZefL9tG6UbvhfRZMHJ"
+	_, xerr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+	})
+	if xerr == nil {
+		t.Fatalf("Expected error when preprocessing transfers from different accounts")
+	}
+}
+
+func TestConstructSigners(t *testing.T) {
+	spender := mustHexAddr("1200000000000000000000000000000000000000")
+	for _, tc := range []struct {
+		opts *model.ConstructOptions
+		want []common.Address
+	}{{
+		opts: &model.ConstructOptions{From: testAcct[:], Payer: govAddr[:]},
+		want: []common.Address{testAcct, govAddr},
+	}, {
+		opts: &model.ConstructOptions{Approve: true, From: testAcct[:], Payer: testAcct[:], Spender: spender[:]},
+		want: []common.Address{testAcct, testAcct},
+	}, {
+		opts: &model.ConstructOptions{From: testAcct[:], Payer: spender[:], Spender: spender[:]},
+		want: []common.Address{spender, spender},
+	}} {
+		if got := constructSigners(tc.opts); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Unexpected signers for %s: got %v, want %v", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestEvmConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := &types.PublicKey{
+		Bytes:     ethcrypto.CompressPubkey(&priv.PublicKey),
+		CurveType: types.Secp256k1,
+	}
+	derived, xerr := s.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		PublicKey: pk,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to derive secp256k1 address: %s", xerr.Message)
+	}
+	evmAddr := ethcrypto.PubkeyToAddress(priv.PublicKey)
+	if got := derived.Metadata["evm_address"]; got != evmAddr.Hex() {
+		t.Fatalf("Unexpected evm_address: got %v, want %s", got, evmAddr.Hex())
+	}
+	from := derived.AccountIdentifier.Address
+	if addr := common.Address(evmAddr); from != addr.ToBase58() {
+		t.Fatalf("Unexpected derived address: %s", from)
+	}
+	ops := testTransferOps(from, "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV", 100)
+	for _, op := range ops {
+		op.Amount.Currency = &types.Currency{
+			Decimals: 18,
+			Metadata: map[string]interface{}{
+				"contract": ongAddr.ToHexString(),
+			},
+			Symbol: "ONG",
+		}
+	}
+	pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to preprocess transfer: %s", xerr.Message)
+	}
+	// NOTE(tav): The metadata endpoint needs a running node, so we set the
+	// options that it would for the secp256k1 key directly.
+	opts := &model.ConstructOptions{}
+	if xerr := decodeProtobuf(pre.Options, opts); xerr != nil {
+		t.Fatalf("Failed to decode options: %s", xerr.Message)
+	}
+	evm, xerr := isEvmTransfer(opts, []*types.PublicKey{pk})
+	if xerr != nil || !evm {
+		t.Fatalf("Expected an EVM transfer for a secp256k1 key: %v", xerr)
+	}
+	opts.Evm = true
+	opts.GasLimit = evmMinGasLimit
+	opts.Nonce = 7
+	enc, err := proto.Marshal(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Metadata:   map[string]interface{}{"protobuf": hex.EncodeToString(enc)},
+		Operations: ops,
+		PublicKeys: []*types.PublicKey{pk},
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to get payloads: %s", xerr.Message)
+	}
+	payload := payloads.Payloads[0]
+	if payload.SignatureType != types.EcdsaRecovery {
+		t.Fatalf("Unexpected signature type: got %q, want %q", payload.SignatureType, types.EcdsaRecovery)
+	}
+	unsigned, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payloads.UnsignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to parse unsigned transaction: %s", xerr.Message)
+	}
+	if len(unsigned.Operations) != 2 || unsigned.Operations[0].Account.Address != from ||
+		unsigned.Operations[1].Amount.Value != "100" {
+		t.Fatalf("Unexpected operations for unsigned transaction: %v", unsigned.Operations)
+	}
+	sig, err := ethcrypto.Sign(payload.Bytes, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined, xerr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		Signatures: []*types.Signature{{
+			Bytes:          sig,
+			PublicKey:      pk,
+			SignatureType:  types.EcdsaRecovery,
+			SigningPayload: payload,
+		}},
+		UnsignedTransaction: payloads.UnsignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to combine signatures: %s", xerr.Message)
+	}
+	txn, xerr := decodeTransaction(combined.SignedTransaction)
+	if xerr != nil {
+		t.Fatalf("Failed to decode signed transaction: %s", xerr.Message)
+	}
+	if !txn.IsEipTx() || txn.Payer != common.Address(evmAddr) || txn.Nonce != 7 {
+		t.Fatalf("Unexpected signed transaction: %v", txn)
+	}
+	parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Signed:      true,
+		Transaction: combined.SignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to parse signed transaction: %s", xerr.Message)
+	}
+	if signer := parsed.AccountIdentifierSigners[0].Address; signer != from {
+		t.Fatalf("Unexpected signer: got %s, want %s", signer, from)
+	}
+	if !reflect.DeepEqual(parsed.Operations, unsigned.Operations) {
+		t.Fatalf("Unexpected operations for signed transaction: %v", parsed.Operations)
+	}
+	sig[0] ^= 0xff
+	_, xerr = s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		Signatures: []*types.Signature{{
+			Bytes:          sig,
+			PublicKey:      pk,
+			SignatureType:  types.EcdsaRecovery,
+			SigningPayload: payload,
+		}},
+		UnsignedTransaction: payloads.UnsignedTransaction,
+	})
+	if xerr == nil {
+		t.Fatalf("Expected an invalid signature to fail")
+	}
+}
+
+func TestFeeConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	ops := testTransferOps(testAcct.ToBase58(), "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV", 100)
+	ong := &types.Currency{
+		Decimals: 18,
+		Metadata: map[string]interface{}{
+			"contract": ongAddr.ToHexString(),
+		},
+		Symbol: "ONG",
+	}
+	multiplier := 1.5
+	pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		MaxFee: []*types.Amount{{
+			Currency: ong,
+			Value:    "60000000000000000",
+		}},
+		Metadata: map[string]interface{}{
+			"gas_limit": float64(20000),
+			"gas_price": float64(3000),
+		},
+		Operations:             ops,
+		SuggestedFeeMultiplier: &multiplier,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to preprocess transfer: %s", xerr.Message)
+	}
+	opts := &model.ConstructOptions{}
+	if xerr := decodeProtobuf(pre.Options, opts); xerr != nil {
+		t.Fatalf("Failed to decode options: %s", xerr.Message)
+	}
+	if opts.GasPrice != 3000 {
+		t.Fatalf("Unexpected gas price: got %d, want 3000", opts.GasPrice)
+	}
+	if opts.FeeMultiplier != multiplier {
+		t.Fatalf("Unexpected fee multiplier: got %v, want %v", opts.FeeMultiplier, multiplier)
+	}
+	fee, xerr := checkMaxFee(opts)
+	if xerr != nil {
+		t.Fatalf("Unexpected error checking max fee: %s", xerr.Message)
+	}
+	if fee.String() != "60000000000000000" {
+		t.Fatalf("Unexpected fee: got %s, want 60000000000000000", fee)
+	}
+	_, xerr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		MaxFee: []*types.Amount{{
+			Currency: ong,
+			Value:    "59999999999999999",
+		}},
+		Metadata: map[string]interface{}{
+			"gas_limit": float64(20000),
+			"gas_price": float64(3000),
+		},
+		Operations: ops,
+	})
+	if xerr == nil || xerr.Code != errMaxFeeExceeded.Code {
+		t.Fatalf("Expected max fee exceeded error, got: %v", xerr)
+	}
+	_, xerr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		MaxFee: []*types.Amount{{
+			Currency: ops[0].Amount.Currency,
+			Value:    "100",
+		}},
+		Operations: ops,
+	})
+	if xerr == nil || xerr.Code != errInvalidMaxFee.Code {
+		t.Fatalf("Expected invalid max fee error for an ONT max_fee, got: %v", xerr)
+	}
+	_, xerr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Metadata: map[string]interface{}{
+			"gas_price": float64(defaultGasPrice - 1),
+		},
+		Operations: ops,
+	})
+	if xerr == nil || xerr.Code != errInvalidGasPrice.Code {
+		t.Fatalf("Expected invalid gas price error for a low gas_price, got: %v", xerr)
+	}
+}
+
+func TestMultisigConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	var (
+		privs []ed25519.PrivateKey
+		pubs  []keypair.PublicKey
+		pks   []*types.PublicKey
+	)
+	for i := 0; i < 3; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+		pks = append(pks, &types.PublicKey{
+			Bytes:     pub,
+			CurveType: types.Edwards25519,
+		})
+	}
+	// NOTE(tav): AddressFromMultiPubKeys sorts the given keys in place.
+	addr, err := ctypes.AddressFromMultiPubKeys(append([]keypair.PublicKey{}, pubs...), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := testMetadata(t, map[string]interface{}{"m": 2, "public_keys": pks})
+	derived, xerr := s.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		Metadata:  md,
+		PublicKey: pks[1],
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to derive multi-sig address: %s", xerr.Message)
+	}
+	if derived.AccountIdentifier.Address != addr.ToBase58() {
+		t.Fatalf(
+			"Unexpected multi-sig address: got %s, want %s",
+			derived.AccountIdentifier.Address, addr.ToBase58(),
+		)
+	}
+	ops := testTransferOps(addr.ToBase58(), "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV", 100)
+	pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Metadata:   md,
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to preprocess transfer: %s", xerr.Message)
+	}
+	payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Metadata:   pre.Options,
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to get payloads: %s", xerr.Message)
+	}
+	if len(payloads.Payloads) != 3 {
+		t.Fatalf("Unexpected number of payloads: got %d, want 3", len(payloads.Payloads))
+	}
+	sign := func(idxs ...int) []*types.Signature {
+		var sigs []*types.Signature
+		for _, idx := range idxs {
+			hash := payloads.Payloads[0].Bytes
+			sigs = append(sigs, &types.Signature{
+				Bytes:         ed25519.Sign(privs[idx], hash),
+				PublicKey:     pks[idx],
+				SignatureType: types.Ed25519,
+				SigningPayload: &types.SigningPayload{
+					Bytes:         hash,
+					SignatureType: types.Ed25519,
+				},
+			})
+		}
+		return sigs
+	}
+	_, xerr = s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		Signatures:          sign(0),
+		UnsignedTransaction: payloads.UnsignedTransaction,
+	})
+	if xerr == nil {
+		t.Fatalf("Expected error when combining too few multi-sig signatures")
+	}
+	combined, xerr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		Signatures:          sign(2, 0),
+		UnsignedTransaction: payloads.UnsignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to combine signatures: %s", xerr.Message)
+	}
+	txn, xerr := decodeTransaction(combined.SignedTransaction)
+	if xerr != nil {
+		t.Fatalf("Failed to decode signed transaction: %s", xerr.Message)
+	}
+	if code := validation.VerifyTransaction(txn); code != errors.ErrNoError {
+		t.Fatalf("Failed to verify signed transaction: %s", code)
+	}
+	parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Signed:      true,
+		Transaction: combined.SignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to parse signed transaction: %s", xerr.Message)
+	}
+	got := []string{}
+	for _, signer := range parsed.AccountIdentifierSigners {
+		got = append(got, signer.Address)
+	}
+	want := []string{}
+	for _, idx := range []int{0, 2} {
+		addr := ctypes.AddressFromPubKey(pubs[idx])
+		want = append(want, addr.ToBase58())
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Unexpected signers: got %v, want %v", got, want)
+	}
+}
+
+func TestParseContractCall(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	unknown := mustHexAddr("ff31ec74d01f7b7d45ed2add930f5d2239f7de33")
+	to := mustHexAddr("1200000000000000000000000000000000000000")
+	oep4Transfer, err := utils.BuildNeoVMInvokeCode(unknown, []interface{}{"transfer",
+		[]interface{}{testAcct, to, big.NewInt(100)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nativeCall, err := utils.BuildNativeInvokeCode(ontAddr, 0, "name", []interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		code     []byte
+		contract common.Address
+		method   string
+	}{
+		{oep4Transfer, unknown, "transfer"},
+		{nativeCall, ontAddr, "name"},
+	} {
+		mut := &ctypes.MutableTransaction{
+			GasLimit: minGasLimit,
+			GasPrice: defaultGasPrice,
+			Payer:    testAcct,
+			Payload:  &payload.InvokeCode{Code: tc.code},
+			Sigs:     []ctypes.Sig{},
+			TxType:   ctypes.InvokeNeo,
+		}
+		txn, err := mut.IntoImmutable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink := common.ZeroCopySink{}
+		txn.Serialization(&sink)
+		parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Transaction: hex.EncodeToString(sink.Bytes()),
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to parse %s call: %s", tc.method, xerr.Message)
+		}
+		if len(parsed.Operations) != 1 {
+			t.Fatalf("Unexpected number of operations: got %d, want 1", len(parsed.Operations))
+		}
+		op := parsed.Operations[0]
+		if op.Type != opContractCall {
+			t.Fatalf("Unexpected operation type: got %q, want %q", op.Type, opContractCall)
+		}
+		if op.Metadata["contract"] != tc.contract.ToHexString() || op.Metadata["method"] != tc.method {
+			t.Fatalf("Unexpected contract call metadata: %v", op.Metadata)
+		}
+	}
+}
+
+func TestSecp256r1Construction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := &types.PublicKey{
+		Bytes:     elliptic.MarshalCompressed(elliptic.P256(), priv.X, priv.Y),
+		CurveType: types.Secp256r1,
+	}
+	derived, xerr := s.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		PublicKey: pk,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to derive secp256r1 address: %s", xerr.Message)
+	}
+	from := derived.AccountIdentifier.Address
+	ops := testTransferOps(from, "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV", 100)
+	pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to preprocess transfer: %s", xerr.Message)
+	}
+	if len(pre.RequiredPublicKeys) != 1 || pre.RequiredPublicKeys[0].Address != from {
+		t.Fatalf("Unexpected required_public_keys: %v", pre.RequiredPublicKeys)
+	}
+	payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Metadata:   pre.Options,
+		Operations: ops,
+		PublicKeys: []*types.PublicKey{pk},
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to get payloads: %s", xerr.Message)
+	}
+	payload := payloads.Payloads[0]
+	if payload.SignatureType != types.Ecdsa {
+		t.Fatalf("Unexpected signature type: got %q, want %q", payload.SignatureType, types.Ecdsa)
+	}
+	r, sv, err := ecdsa.Sign(rand.Reader, priv, payload.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	sv.FillBytes(sig[32:])
+	combined, xerr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		Signatures: []*types.Signature{{
+			Bytes:          sig,
+			PublicKey:      pk,
+			SignatureType:  types.Ecdsa,
+			SigningPayload: payload,
+		}},
+		UnsignedTransaction: payloads.UnsignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to combine signatures: %s", xerr.Message)
+	}
+	txn, xerr := decodeTransaction(combined.SignedTransaction)
+	if xerr != nil {
+		t.Fatalf("Failed to decode signed transaction: %s", xerr.Message)
+	}
+	if code := validation.VerifyTransaction(txn); code != errors.ErrNoError {
+		t.Fatalf("Failed to verify signed transaction: %s", code)
+	}
+	parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Signed:      true,
+		Transaction: combined.SignedTransaction,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to parse signed transaction: %s", xerr.Message)
+	}
+	if signer := parsed.AccountIdentifierSigners[0].Address; signer != from {
+		t.Fatalf("Unexpected signer: got %s, want %s", signer, from)
+	}
+}
+
+func TestStakingConstruction(t *testing.T) {
+	ctx := context.Background()
+	s := &service{store: newTestStore(t)}
+	acct := testAcct.ToBase58()
+	peer := "02bcdd278a27e4969d48de95d6b7b086b65b8d1d4ff6509e7a9eab364a76115af7"
+	staking := func() *types.AccountIdentifier {
+		return &types.AccountIdentifier{
+			Address: acct,
+			SubAccount: &types.SubAccountIdentifier{
+				Address:  subAcctStaking,
+				Metadata: map[string]interface{}{"peer_pubkey": peer},
+			},
+		}
+	}
+	stake := testTransferOps(acct, acct, 500000000000)
+	stake[1].Account = staking()
+	withdraw := testTransferOps(acct, acct, 500000000000)
+	withdraw[0].Account = staking()
+	for i := range stake {
+		stake[i].Type = opStake
+		withdraw[i].Type = opWithdraw
+	}
+	unstake := []*types.Operation{{
+		Account: staking(),
+		Metadata: testMetadata(t, map[string]interface{}{
+			"amount":   "500000000000",
+			"currency": stake[0].Amount.Currency,
+		}),
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opUnstake,
+	}}
+	withdrawFee := []*types.Operation{{
+		Account:             &types.AccountIdentifier{Address: acct},
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opWithdrawFee,
+	}}
+	for _, tc := range []struct {
+		method string
+		ops    []*types.Operation
+	}{
+		{"authorizeForPeer", stake},
+		{"unAuthorizeForPeer", unstake},
+		{"withdraw", withdraw},
+		{"withdrawFee", withdrawFee},
+	} {
+		pre, xerr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+			Operations: tc.ops,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to preprocess %s: %s", tc.method, xerr.Message)
+		}
+		payloads, xerr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Metadata:   pre.Options,
+			Operations: tc.ops,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to get payloads for %s: %s", tc.method, xerr.Message)
+		}
+		if len(payloads.Payloads) != 1 || payloads.Payloads[0].AccountIdentifier.Address != acct {
+			t.Fatalf("Unexpected payloads for %s: %v", tc.method, payloads.Payloads)
+		}
+		txn, xerr := decodeTransaction(payloads.UnsignedTransaction)
+		if xerr != nil {
+			t.Fatalf("Failed to decode transaction for %s: %s", tc.method, xerr.Message)
+		}
+		inv, err := chain.ParseInvoke(txn.Payload.(*payload.InvokeCode).Code)
+		if err != nil {
+			t.Fatalf("Failed to parse payload for %s: %s", tc.method, err)
+		}
+		if inv.Contract != govAddr || inv.Method != tc.method {
+			t.Fatalf("Unexpected invoke for %s: %s.%s", tc.method, inv.Contract.ToHexString(), inv.Method)
+		}
+		if tc.method != "withdrawFee" && inv.Stakes[0].Amount.Int64() != 500 {
+			t.Fatalf("Unexpected stake amount for %s: %s", tc.method, inv.Stakes[0].Amount)
+		}
+		parsed, xerr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Transaction: payloads.UnsignedTransaction,
+		})
+		if xerr != nil {
+			t.Fatalf("Failed to parse %s: %s", tc.method, xerr.Message)
+		}
+		if len(parsed.Operations) != len(tc.ops) {
+			t.Fatalf("Unexpected number of operations for %s: %d", tc.method, len(parsed.Operations))
+		}
+		for i, op := range parsed.Operations {
+			want := tc.ops[i]
+			if op.Type != want.Type || !reflect.DeepEqual(op.Account, want.Account) {
+				t.Fatalf("Unexpected operation at offset %d for %s: %v", i, tc.method, op)
+			}
+			if (op.Amount == nil) != (want.Amount == nil) ||
+				(op.Amount != nil && op.Amount.Value != want.Amount.Value) {
+				t.Fatalf("Unexpected amount at offset %d for %s: %v", i, tc.method, op.Amount)
+			}
+			if fmt.Sprint(op.Metadata["amount"]) != fmt.Sprint(want.Metadata["amount"]) {
+				t.Fatalf("Unexpected metadata at offset %d for %s: %v", i, tc.method, op.Metadata)
+			}
+		}
+	}
+	invalid := testTransferOps(acct, acct, 1500000000)
+	invalid[1].Account = staking()
+	for i := range invalid {
+		invalid[i].Type = opStake
+	}
+	if _, xerr := s.validateOps(invalid); xerr == nil {
+		t.Fatalf("Expected staking of fractional ONT to fail")
+	}
+}
+
+func testMetadata(t *testing.T, v interface{}) map[string]interface{} {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := map[string]interface{}{}
+	if err := json.Unmarshal(enc, &md); err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func testTransferOps(from string, to string, amount int64) []*types.Operation {
+	currency := &types.Currency{
+		Decimals: 9,
+		Metadata: map[string]interface{}{
+			"contract": ontAddr.ToHexString(),
+		},
+		Symbol: "ONT",
+	}
+	return []*types.Operation{{
+		Account: &types.AccountIdentifier{Address: from},
+		Amount: &types.Amount{
+			Currency: currency,
+			Value:    fmt.Sprint(-amount),
+		},
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opTransfer,
+	}, {
+		Account: &types.AccountIdentifier{Address: to},
+		Amount: &types.Amount{
+			Currency: currency,
+			Value:    fmt.Sprint(amount),
+		},
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+		Type:                opTransfer,
+	}}
+}
@@ -42,6 +42,9 @@ var (
 	errInternal              = newError(304, "unexpected internal error", true)
 	errNonceGenerationFailed = newError(305, "nonce generation failed", true)
 	errProtobuf              = newError(306, "protobuf error", false)
+	errCallFailed            = newError(307, "contract call failed", false)
+	errPreExecutionFailed    = newError(308, "transaction pre-execution failed", false)
+	errTokenVerification     = newError(309, "token verification failed", false)
 	// input validation errors
 	errInvalidAccountAddress     = newError(401, "invalid account address", false)
 	errInvalidBlockHash          = newError(402, "invalid block hash", false)
@@ -60,13 +63,24 @@ var (
 	errInvalidSignature          = newError(415, "invalid signature", false)
 	errInvalidTransactionHash    = newError(416, "invalid transaction hash", false)
 	errInvalidTransactionPayload = newError(417, "invalid transaction payload", false)
+	errInvalidCallMethod         = newError(418, "invalid call method", false)
+	errInvalidCallParameters     = newError(419, "invalid call parameters", false)
+	errInvalidSubAccount         = newError(420, "invalid sub-account", false)
+	errInvalidMaxFee             = newError(421, "invalid max fee", false)
+	errMaxFeeExceeded            = newError(422, "max fee exceeded", false)
 	// potentially retriable errors
 	errBroadcastFailed         = newError(501, "broadcast failed", true)
 	errTransactionNotInMempool = newError(502, "transaction not in mempool", true)
 	errUnknownBlockHash        = newError(503, "unknown block hash", true)
 	errUnknownBlockIndex       = newError(504, "unknown block index", true)
+	errUnknownTransactionHash  = newError(505, "unknown transaction hash", true)
+	errCurrencyUnavailable     = newError(506, "currency not yet available", true)
 )
 
+func invalidCallf(format string, args ...interface{}) *types.Error {
+	return wrapErr(errInvalidCallParameters, fmt.Errorf("services: "+format, args...))
+}
+
 func invalidConstructf(format string, args ...interface{}) *types.Error {
 	return wrapErr(errInvalidConstructOptions, fmt.Errorf("services: "+format, args...))
 }
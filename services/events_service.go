@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package services
+
+import (
+	"context"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const eventsLimit = 100
+
+// EventsBlocks implements the /events/blocks endpoint.
+func (s *service) EventsBlocks(ctx context.Context, r *types.EventsBlocksRequest) (*types.EventsBlocksResponse, *types.Error) {
+	if s.offline {
+		return nil, errOfflineMode
+	}
+	limit := int64(eventsLimit)
+	if r.Limit != nil && *r.Limit < limit {
+		limit = *r.Limit
+	}
+	events, max, xerr := s.store.getBlockEvents(r.Offset, limit)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if events == nil {
+		events = []*types.BlockEvent{}
+	}
+	return &types.EventsBlocksResponse{
+		Events:      events,
+		MaxSequence: max,
+	}, nil
+}
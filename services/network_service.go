@@ -38,6 +38,7 @@ func (s *service) NetworkList(ctx context.Context, r *types.MetadataRequest) (*t
 func (s *service) NetworkOptions(ctx context.Context, r *types.NetworkRequest) (*types.NetworkOptionsResponse, *types.Error) {
 	return &types.NetworkOptionsResponse{
 		Allow: &types.Allow{
+			CallMethods:             callMethods,
 			Errors:                  serverErrors,
 			HistoricalBalanceLookup: true,
 			OperationStatuses: []*types.OperationStatus{
@@ -47,6 +48,10 @@ func (s *service) NetworkOptions(ctx context.Context, r *types.NetworkRequest) (
 			OperationTypes: opTypes,
 		},
 		Version: &types.Version{
+			Metadata: map[string]interface{}{
+				"curve_types":     curveTypes,
+				"signature_types": signatureTypes,
+			},
 			NodeVersion:    version.Node,
 			RosettaVersion: version.Rosetta,
 		},
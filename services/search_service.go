@@ -0,0 +1,284 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ontio/ontology-rosetta/log"
+	"github.com/ontio/ontology-rosetta/model"
+	"github.com/ontio/ontology/common"
+)
+
+const searchLimit = 100
+
+// SearchTransactions implements the /search/transactions endpoint.
+func (s *service) SearchTransactions(ctx context.Context, r *types.SearchTransactionsRequest) (*types.SearchTransactionsResponse, *types.Error) {
+	if s.offline {
+		return nil, errOfflineMode
+	}
+	q, xerr := s.newSearchQuery(r)
+	if xerr != nil {
+		return nil, xerr
+	}
+	results, total, xerr := s.store.searchTransactions(q)
+	if xerr != nil {
+		return nil, xerr
+	}
+	txns := make([]*types.BlockTransaction, len(results))
+	for i, result := range results {
+		dst, xerr, err := s.transformTransaction(result.txn)
+		if xerr != nil {
+			return nil, xerr
+		}
+		if err != nil {
+			log.Errorf(
+				"Consistency failure when decoding transaction %x at block %d: %s",
+				result.txn.Hash, result.height, err,
+			)
+			return nil, wrapErr(errDatastoreConsistency, err)
+		}
+		txns[i] = &types.BlockTransaction{
+			BlockIdentifier: result.blockID,
+			Transaction:     dst,
+		}
+	}
+	resp := &types.SearchTransactionsResponse{
+		Transactions: txns,
+		TotalCount:   total,
+	}
+	if next := q.offset + int64(len(txns)); next < total {
+		resp.NextOffset = &next
+	}
+	return resp, nil
+}
+
+func (s *service) newSearchQuery(r *types.SearchTransactionsRequest) (*searchQuery, *types.Error) {
+	if r.CoinIdentifier != nil {
+		return nil, wrapErr(
+			errInvalidRequestField,
+			fmt.Errorf("services: coin_identifier is not supported"),
+		)
+	}
+	q := &searchQuery{
+		limit:    searchLimit,
+		maxBlock: s.store.getHeight(),
+		or:       r.Operator != nil && *r.Operator == types.OR,
+		success:  r.Success,
+	}
+	if r.AccountIdentifier != nil {
+		acct, err := common.AddressFromBase58(r.AccountIdentifier.Address)
+		if err != nil {
+			return nil, errInvalidAccountAddress
+		}
+		q.acct = addr2slice(acct)
+		if r.AccountIdentifier.SubAccount != nil {
+			contract, err := parseContract(r.AccountIdentifier.SubAccount.Address)
+			if err != nil {
+				return nil, errInvalidContractAddress
+			}
+			q.acctContract = addr2slice(contract)
+		}
+	}
+	if r.Address != nil {
+		addr, err := common.AddressFromBase58(*r.Address)
+		if err != nil {
+			return nil, errInvalidAccountAddress
+		}
+		q.address = addr2slice(addr)
+	}
+	if r.Currency != nil {
+		info, xerr := s.store.validateCurrency(r.Currency)
+		if xerr != nil {
+			return nil, xerr
+		}
+		q.contract = addr2slice(info.contract)
+	}
+	if r.Limit != nil && *r.Limit < searchLimit {
+		q.limit = int(*r.Limit)
+	}
+	if r.MaxBlock != nil && *r.MaxBlock < int64(q.maxBlock) {
+		q.maxBlock = uint32(*r.MaxBlock)
+	}
+	if r.Offset != nil {
+		q.offset = *r.Offset
+	}
+	if r.Status != nil {
+		switch *r.Status {
+		case statusFailed, statusSuccess:
+			q.status = *r.Status
+		default:
+			return nil, wrapErr(
+				errInvalidRequestField,
+				fmt.Errorf("services: unknown operation status %q", *r.Status),
+			)
+		}
+	}
+	if r.TransactionIdentifier != nil {
+		txhash, err := common.Uint256FromHexString(r.TransactionIdentifier.Hash)
+		if err != nil {
+			return nil, errInvalidTransactionHash
+		}
+		q.txhash = &txhash
+	}
+	if r.Type != nil {
+		found := false
+		for _, typ := range opTypes {
+			if typ == *r.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, wrapErr(
+				errInvalidRequestField,
+				fmt.Errorf("services: unknown operation type %q", *r.Type),
+			)
+		}
+		q.typ = *r.Type
+	}
+	return q, nil
+}
+
+// match returns whether the given transaction satisfies the query's
+// conditions. With the AND operator, a single operation needs to satisfy all
+// of the operation-level conditions.
+func (q *searchQuery) match(txn *model.Transaction) bool {
+	hashMatch := q.txhash != nil && bytes.Equal(txn.Hash, q.txhash[:])
+	if q.or && hashMatch {
+		return true
+	}
+	if !q.or && q.txhash != nil && !hashMatch {
+		return false
+	}
+	if !q.or && q.opConditions() == 0 {
+		return true
+	}
+	for _, xfer := range txn.Transfers {
+		for _, op := range transferOps(xfer) {
+			if q.matchOp(xfer, op) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (q *searchQuery) matchOp(xfer *model.Transfer, op transferOp) bool {
+	checks, hits := 0, 0
+	check := func(ok bool) {
+		checks++
+		if ok {
+			hits++
+		}
+	}
+	if q.acct != nil {
+		contract := q.acctContract
+		native := contract == nil && (bytes.Equal(xfer.Contract, addr2slice(ontAddr)) ||
+			bytes.Equal(xfer.Contract, addr2slice(ongAddr)))
+		check(bytes.Equal(op.acct, q.acct) && (native || bytes.Equal(xfer.Contract, contract)))
+	}
+	if q.address != nil {
+		check(bytes.Equal(op.acct, q.address))
+	}
+	if q.contract != nil {
+		check(bytes.Equal(xfer.Contract, q.contract))
+	}
+	if q.status != "" {
+		// NOTE(tav): All indexed operations have the statusSuccess status.
+		check(q.status == statusSuccess)
+	}
+	if q.success != nil {
+		check(*q.success)
+	}
+	if q.typ != "" {
+		check(op.typ == q.typ)
+	}
+	if q.or {
+		return hits > 0
+	}
+	return hits == checks
+}
+
+func (q *searchQuery) opConditions() int {
+	n := 0
+	for _, set := range []bool{
+		q.acct != nil,
+		q.address != nil,
+		q.contract != nil,
+		q.status != "",
+		q.success != nil,
+		q.typ != "",
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// sources returns the secondary index prefixes to iterate over for candidate
+// transactions, whether the transaction hash should also be looked up, and
+// whether every candidate is known to match without checking its operations.
+func (q *searchQuery) sources() (prefixes [][]byte, byHash bool, exact bool) {
+	all := [][]byte{{'l'}}
+	conds := q.opConditions()
+	if q.txhash != nil {
+		conds++
+	}
+	if conds == 0 {
+		return all, false, true
+	}
+	if !q.or {
+		exact = conds == 1
+		switch {
+		case q.txhash != nil:
+			return nil, true, exact
+		case q.address != nil:
+			return [][]byte{addressTxnsPrefix(q.address)}, false, exact
+		case q.acct != nil:
+			return [][]byte{addressTxnsPrefix(q.acct)}, false, false
+		case q.contract != nil:
+			return [][]byte{currencyTxnsPrefix(q.contract)}, false, exact
+		case q.typ != "":
+			return [][]byte{opTypeTxnsPrefix(q.typ)}, false, exact
+		}
+		return all, false, false
+	}
+	if q.status != "" || q.success != nil {
+		return all, false, false
+	}
+	exact = q.acct == nil
+	if q.acct != nil {
+		prefixes = append(prefixes, addressTxnsPrefix(q.acct))
+	}
+	if q.address != nil {
+		prefixes = append(prefixes, addressTxnsPrefix(q.address))
+	}
+	if q.contract != nil {
+		prefixes = append(prefixes, currencyTxnsPrefix(q.contract))
+	}
+	if q.typ != "" {
+		prefixes = append(prefixes, opTypeTxnsPrefix(q.typ))
+	}
+	return prefixes, q.txhash != nil, exact
+}
@@ -20,29 +20,55 @@
 package services
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/dgraph-io/badger/v3"
 	ethcom "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/ontio/ontology-rosetta/chain"
 	"github.com/ontio/ontology-rosetta/model"
 	"github.com/ontio/ontology/common"
 	"github.com/ontio/ontology/common/config"
 	ctypes "github.com/ontio/ontology/core/types"
 	"github.com/ontio/ontology/p2pserver"
 	"github.com/ontio/ontology/smartcontract/service/neovm"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
-	defaultGasPrice = 2500
-	opBurn          = "burn"
-	opGasFee        = "gas_fee"
-	opMint          = "mint"
-	opTransfer      = "transfer"
+	callNativeInvoke = "native_invoke"
+	callClaimableONG = "claimable_ong"
+	callNeovmInvoke  = "neovm_invoke"
+	callOEP4Balance  = "oep4_balance_of"
+	callWasmInvoke   = "wasm_invoke"
+	defaultGasPrice  = 2500
+	evmMinGasLimit   = 21000
+	evmTokenGasLimit = 100000
+	opApprove        = "approve"
+	opBurn           = "burn"
+	opClaimONG       = "claim_ong"
+	opContractCall   = "contract_call"
+	opGasFee         = "gas_fee"
+	opMint           = "mint"
+	opStake          = "stake"
+	opTransfer       = "transfer"
+	opTransferFrom   = "transfer_from"
+	opUnstake        = "unstake"
+	opWithdraw       = "withdraw"
+	opWithdrawFee    = "withdraw_fee"
+	peerPubkeySize   = 33
+	subAcctStaking   = "staking"
 )
 
 var (
@@ -56,7 +82,7 @@ var (
 )
 
 // ERC20ABI is the input ABI used to generate the binding from.
-const ERC20ABI = "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"from\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Transfer\",\"type\":\"event\"}]"
+const ERC20ABI = "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"from\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Transfer\",\"type\":\"event\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"transfer\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
 
 type ERC20Transfer struct {
 	From  ethcom.Address
@@ -66,32 +92,76 @@ type ERC20Transfer struct {
 }
 
 var (
-	minGasLimit   = neovm.MIN_TRANSACTION_GAS
-	opTypes       = []string{opBurn, opGasFee, opMint, opTransfer}
-	statusFailed  = "FAILED"
-	statusSuccess = "SUCCESS"
+	callMethods    = []string{callClaimableONG, callNativeInvoke, callNeovmInvoke, callOEP4Balance, callWasmInvoke}
+	curveTypes     = []types.CurveType{types.Edwards25519, types.Secp256k1, types.Secp256r1}
+	minGasLimit    = neovm.MIN_TRANSACTION_GAS
+	opTypes        = []string{opApprove, opBurn, opClaimONG, opContractCall, opGasFee, opMint, opStake, opTransfer, opTransferFrom, opUnstake, opWithdraw, opWithdrawFee}
+	signatureTypes = []types.SignatureType{types.Ed25519, types.Ecdsa, types.EcdsaRecovery}
+	statusFailed   = "FAILED"
+	statusSuccess  = "SUCCESS"
 )
 
-// OEP4Token defines the currency information for an OEP4 token.
-type OEP4Token struct {
+// ERC20Token defines the currency information for an ERC-20 token on the
+// Ontology EVM.
+type ERC20Token struct {
 	Contract common.Address
 	Decimals int32
 	Symbol   string
-	Wasm     bool
+}
+
+// OEP4Token defines the currency information for an OEP4 token. If Symbol is
+// empty, the Decimals, Name, and Symbol are filled in by NewStore, either from
+// the contract on chain, or from the metadata cached within the store.
+//
+// If the token is added after the store has started indexing, its history is
+// backfilled from the StartHeight.
+type OEP4Token struct {
+	Contract    common.Address
+	Decimals    int32
+	Name        string
+	StartHeight uint32
+	Symbol      string
+	Wasm        bool
 }
 
 // IndexConfig represents the options for the IndexBlocks method on Store.
 type IndexConfig struct {
+	BatchSize int
 	Done      chan bool
 	ExitEarly bool
+	Prefetch  int
 	WaitTime  time.Duration
+	Workers   int
 }
 
+// RouterConfig represents the options for the Rosetta API Router.
+type RouterConfig struct {
+	// GasMargin is the percentage added to the gas consumed when pre-executing
+	// a transaction, in order to derive its gas limit.
+	GasMargin uint64
+	Offline   bool
+	// VerifyTokens enables the verification of OEP4 token contracts on each
+	// /construction/metadata request, in addition to at startup.
+	VerifyTokens bool
+}
+
+// accountInfo represents an account/contract combination within the store. If
+// peer is set, it represents the ONT staked with that consensus node instead.
 type accountInfo struct {
 	acct     common.Address
 	contract common.Address
 	key      []byte
 	native   bool
+	peer     []byte
+}
+
+// backfillState represents the transfers of a single OEP4 token within an
+// already indexed block, keyed by the offset of their transaction.
+type backfillState struct {
+	changes   []*balanceChange
+	hash      common.Uint256
+	height    uint32
+	transfers map[int][]*model.Transfer
 }
 
 type balanceChange struct {
@@ -129,12 +199,66 @@ type blockState struct {
 	changes []*balanceChange
 	hashes  [][]byte
 	id      *blockID
+	parent  common.Uint256
 	synced  uint32
 }
 
+// writeSize estimates the number of entries and bytes that writeBlock will add
+// to a transaction for the block.
+func (b *blockState) writeSize() (count int64, size int64) {
+	const (
+		entryOverhead = 12
+		hashKeySize   = 1 + common.UINT256_SIZE
+		heightKeySize = 1 + 4
+	)
+	keys := int64(0)
+	for _, acct := range b.changes {
+		count++
+		size += int64(len(acct.key)+len(acct.diff.Bytes())) + entryOverhead
+		keys += int64(len(acct.key)) + 2
+	}
+	for _, hash := range b.hashes {
+		count++
+		size += int64(1+len(hash)) + entryOverhead
+		keys += int64(1+len(hash)) + 2
+	}
+	for offset, txn := range b.block.Transactions {
+		count++
+		size += int64(hashKeySize+8) + entryOverhead
+		keys += hashKeySize + 2
+		for _, key := range txnIndexKeys(b.id.height, uint32(offset), txn) {
+			count++
+			size += int64(len(key)) + entryOverhead
+			keys += int64(len(key)) + 2
+		}
+	}
+	count += 5
+	size += int64(heightKeySize+proto.Size(b.block)) + entryOverhead
+	size += int64(hashKeySize+4) + entryOverhead
+	size += int64(heightKeySize+common.UINT256_SIZE) + entryOverhead
+	size += int64(heightKeySize) + keys + entryOverhead
+	size += int64(1+8+common.UINT256_SIZE+8) + entryOverhead
+	return count, size
+}
+
+type callParam struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type callParams struct {
+	Account  string       `json:"account"`
+	Contract string       `json:"contract"`
+	Method   string       `json:"method"`
+	Params   []*callParam `json:"params"`
+}
+
+// currencyInfo represents a currency that can be indexed and transferred. If
+// evm is set, the currency is an ERC-20 token on the Ontology EVM.
 type currencyInfo struct {
 	contract common.Address
 	currency *types.Currency
+	evm      bool
 	wasm     bool
 }
 
@@ -142,11 +266,108 @@ func (c *currencyInfo) isNative() bool {
 	return c.contract == ongAddr || c.contract == ontAddr
 }
 
+// evmUnsigned represents an unsigned EVM transaction. As the sender can only be
+// recovered from the signature, it is encoded alongside the transaction.
+type evmUnsigned struct {
+	From ethcom.Address
+	Tx   *ethtypes.Transaction
+}
+
+type fetchJob struct {
+	height uint32
+	result chan *fetchResult
+}
+
+type fetchResult struct {
+	err   error
+	state *blockState
+}
+
+type indexCursor struct {
+	it    *badger.Iterator
+	loc   uint64
+	valid bool
+}
+
+func (c *indexCursor) load() {
+	c.valid = c.it.Valid()
+	if c.valid {
+		key := c.it.Item().Key()
+		c.loc = binary.BigEndian.Uint64(key[len(key)-8:])
+	}
+}
+
+func (c *indexCursor) next() {
+	if c.it == nil {
+		c.valid = false
+		return
+	}
+	c.it.Next()
+	c.load()
+}
+
+// multisig represents an M-of-N multi-sig account.
+type multisig struct {
+	addr common.Address
+	keys []keypair.PublicKey
+	m    uint16
+}
+
+func (m *multisig) hasKey(key keypair.PublicKey) bool {
+	for _, mkey := range m.keys {
+		if keypair.ComparePublicKey(key, mkey) {
+			return true
+		}
+	}
+	return false
+}
+
+type searchQuery struct {
+	acct         []byte
+	acctContract []byte
+	address      []byte
+	contract     []byte
+	limit        int
+	maxBlock     uint32
+	offset       int64
+	or           bool
+	status       string
+	success      *bool
+	txhash       *common.Uint256
+	typ          string
+}
+
+type searchResult struct {
+	blockID *types.BlockIdentifier
+	height  uint32
+	txn     *model.Transaction
+}
+
 type service struct {
-	networks []*types.NetworkIdentifier
-	node     *p2pserver.P2PServer
-	offline  bool
-	store    *Store
+	gasMargin    uint64
+	networks     []*types.NetworkIdentifier
+	node         *p2pserver.P2PServer
+	offline      bool
+	store        *Store
+	verifyTokens bool
+}
+
+// stakingInvoke represents a call to one of the governance contract's staking
+// methods. The amounts are in units of 10^-9 ONT, and are not set for
+// withdraw_fee calls.
+type stakingInvoke struct {
+	acct    common.Address
+	amounts []*big.Int
+	peers   [][]byte
+	typ     string
+}
+
+func (s *stakingInvoke) total() *big.Int {
+	total := &big.Int{}
+	for _, amount := range s.amounts {
+		total.Add(total, amount)
+	}
+	return total
 }
 
 type transfer struct {
@@ -156,21 +377,50 @@ type transfer struct {
 	to     common.Address
 }
 
+// transferInfo represents a transfer of a currency. If approve is set, it
+// instead represents an allowance of the amount from the sender to the spender.
+// If staking is set, it represents one of the staking operation types, with
+// the staked ONT held in the account's staking sub-account for the peer.
 type transferInfo struct {
 	amount   *big.Int
+	approve  bool
 	contract common.Address
 	currency *types.Currency
 	from     common.Address
 	isGas    bool
+	peer     []byte
+	spender  common.Address
+	staking  string
 	to       common.Address
 }
 
+// isClaim returns whether the transfer is a claim of unbound ONG, i.e. an ONG
+// transfer from the ONT contract address.
+func (t *transferInfo) isClaim() bool {
+	return t.contract == ongAddr && t.from == ontAddr && !t.isGas
+}
+
 func (t *transferInfo) isNative() bool {
 	return t.contract == ongAddr || t.contract == ontAddr
 }
 
+// signer returns the account that needs to sign for the transfer. For
+// transfer_from and claim_ong operations, this is the spender of the
+// allowance.
+func (t *transferInfo) signer() common.Address {
+	if !t.approve && t.spender != common.ADDRESS_EMPTY {
+		return t.spender
+	}
+	return t.from
+}
+
+type transferOp struct {
+	acct []byte
+	typ  string
+}
+
 // Router creates an http.Handler for Rosetta API requests.
-func Router(node *p2pserver.P2PServer, store *Store, offline bool) (http.Handler, error) {
+func Router(node *p2pserver.P2PServer, store *Store, cfg RouterConfig) (http.Handler, error) {
 	networks := []*types.NetworkIdentifier{{
 		Blockchain: "ontology",
 		Network:    networkName(),
@@ -179,7 +429,7 @@ func Router(node *p2pserver.P2PServer, store *Store, offline bool) (http.Handler
 		opTypes,
 		true,
 		networks,
-		nil,
+		callMethods,
 		false,
 	)
 	if err != nil {
@@ -189,20 +439,60 @@ func Router(node *p2pserver.P2PServer, store *Store, offline bool) (http.Handler
 		)
 	}
 	svc := &service{
-		networks: networks,
-		node:     node,
-		offline:  offline,
-		store:    store,
+		gasMargin:    cfg.GasMargin,
+		networks:     networks,
+		node:         node,
+		offline:      cfg.Offline,
+		store:        store,
+		verifyTokens: cfg.VerifyTokens,
 	}
 	return server.NewRouter(
 		server.NewAccountAPIController(svc, asserter),
 		server.NewBlockAPIController(svc, asserter),
+		server.NewCallAPIController(svc, asserter),
 		server.NewConstructionAPIController(svc, asserter),
+		server.NewEventsAPIController(svc, asserter),
 		server.NewMempoolAPIController(svc, asserter),
 		server.NewNetworkAPIController(svc, asserter),
+		server.NewSearchAPIController(svc, asserter),
+		&transactionController{asserter: asserter, svc: svc},
 	), nil
 }
 
+// VerifyOEP4Token checks the OEP4 token's contract on chain, and errors if it
+// doesn't expose the expected methods, or if its decimals or symbol don't
+// match the config. It returns chain.ErrNotDeployed if the node hasn't synced
+// the contract yet.
+func VerifyOEP4Token(token *OEP4Token) error {
+	info, err := chain.VerifyOEP4(token.Contract, token.Wasm)
+	if err != nil {
+		return err
+	}
+	if info.Decimals != token.Decimals {
+		return fmt.Errorf(
+			"services: mismatching decimals for OEP4 token %s: expected %d, got %d on chain",
+			token.Contract.ToHexString(), token.Decimals, info.Decimals,
+		)
+	}
+	if info.Symbol != token.Symbol {
+		return fmt.Errorf(
+			"services: mismatching symbol for OEP4 token %s: expected %q, got %q on chain",
+			token.Contract.ToHexString(), token.Symbol, info.Symbol,
+		)
+	}
+	return nil
+}
+
+// contractSubAccount returns the sub-account that holds the balances of a
+// non-native currency, i.e. the contract address from its metadata. This is
+// the EVM address for ERC-20 tokens, and the Ontology hex encoding otherwise.
+func contractSubAccount(currency *types.Currency) *types.SubAccountIdentifier {
+	addr, _ := currency.Metadata["contract"].(string)
+	return &types.SubAccountIdentifier{
+		Address: addr,
+	}
+}
+
 func mustHexAddr(s string) common.Address {
 	addr, err := common.AddressFromHexString(s)
 	if err != nil {
@@ -215,6 +505,18 @@ func mustEthHexAddr(s string) ethcom.Address {
 	return ethcom.HexToAddress(s)
 }
 
+// parseContract decodes a contract address from either the hex encoding used by
+// Ontology, or a 0x-prefixed EVM address.
+func parseContract(s string) (common.Address, error) {
+	if strings.HasPrefix(s, "0x") {
+		if !ethcom.IsHexAddress(s) {
+			return common.ADDRESS_EMPTY, fmt.Errorf("services: invalid EVM address: %q", s)
+		}
+		return common.Address(ethcom.HexToAddress(s)), nil
+	}
+	return common.AddressFromHexString(s)
+}
+
 func networkName() string {
 	switch config.DefConfig.P2PNode.NetworkName {
 	case config.NETWORK_NAME_MAIN_NET:
@@ -227,3 +529,14 @@ func networkName() string {
 		return "unknown"
 	}
 }
+
+// stakingSubAccount returns the sub-account that holds the ONT staked with the
+// given consensus node.
+func stakingSubAccount(peer []byte) *types.SubAccountIdentifier {
+	return &types.SubAccountIdentifier{
+		Address: subAcctStaking,
+		Metadata: map[string]interface{}{
+			"peer_pubkey": hex.EncodeToString(peer),
+		},
+	}
+}
@@ -44,16 +44,30 @@ import (
 	"github.com/ontio/ontology-rosetta/model"
 	"github.com/ontio/ontology/common"
 	"github.com/ontio/ontology/common/constants"
+	"github.com/ontio/ontology/core/payload"
 	store "github.com/ontio/ontology/core/store/common"
 	"github.com/ontio/ontology/core/store/ledgerstore"
 	ctypes "github.com/ontio/ontology/core/types"
+	"github.com/ontio/ontology/events/message"
 	"github.com/ontio/ontology/http/base/actor"
 	"github.com/ontio/ontology/smartcontract/event"
 	"google.golang.org/protobuf/proto"
 )
 
 var (
-	jsonNumberType = reflect.TypeOf(json.Number(""))
+	errParentMismatch = fmt.Errorf("services: parent hash does not match indexed block")
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+// NOTE(tav): The node calls that drive indexing are variables, so that they
+// can be replaced within tests.
+var (
+	blockFetcher   = (*Store).fetchBlock
+	chainBlockHash = actor.GetBlockHashFromStore
+	chainHeight    = actor.GetCurrentBlockHeight
+	subscribeSaved = func(handler func(v interface{})) {
+		actor.SubscribeEvent(message.TOPIC_SAVE_BLOCK_COMPLETE, handler)
+	}
 )
 
 // NOTE(tav): We store the blockchain data within Badger using the following
@@ -64,7 +78,18 @@ var (
 // blockHash2HeightKey c<block-hash> = <height-little-endian>
 // blockHeight2HashKey d<height-little-endian> = <block-hash>
 //          txnHashKey e<unsigned-txn-hash> = <nil>
+//          journalKey f<height-little-endian> = Journal
+//      txnLocationKey g<txn-hash> = <height-little-endian><offset-little-endian>
+//     addressTxnsKey i<acct><height-big-endian><offset-big-endian> = <nil>
+//    currencyTxnsKey j<contract><height-big-endian><offset-big-endian> = <nil>
+//      opTypeTxnsKey k<op-type>\x00<height-big-endian><offset-big-endian> = <nil>
+//            txnsKey l<height-big-endian><offset-big-endian> = <nil>
+//      blockEventKey m<sequence-big-endian> = BlockEvent
+//            tokenKey n<contract> = Token
+//       tokenRangeKey o<contract> = TokenRange
 //                     height = <height-little-endian>
+//                    staking = <nil>
+//                     tokens = <nil>
 //
 // We compress some of the native contract addresses, e.g. ONT/ONG, to single
 // bytes so as to reduce space usage. An additional byte is used to indicate
@@ -74,13 +99,50 @@ var (
 // need to write in a single transaction while indexing a block. If this proves
 // insufficient in the future, we can increase the size, or break up the
 // transaction into smaller atomic units.
+//
+// The txnLocationKey entries map each transaction hash to the height of its
+// block and its offset within the block's Transactions, so that transactions
+// can be looked up without scanning blocks.
+//
+// The addressTxnsKey, currencyTxnsKey, opTypeTxnsKey, and txnsKey entries are
+// secondary indexes for the /search/transactions endpoint. Their heights and
+// offsets are big-endian so that iterating over a prefix in reverse yields the
+// most recent transactions first.
+//
+// A BlockEvent is written with the next sequence number whenever a block is
+// added or removed, so that the /events/blocks endpoint can replay the changes
+// to the canonical chain. These entries are never rolled back.
+//
+// The Journal for each block lists the accountKey, txnHashKey, txnLocationKey,
+// and secondary index entries that were written for it, so that blocks can be
+// rolled back, e.g. when the node switches to a different fork, or when an
+// operator wants to re-index from a particular height. For blocks that were
+// indexed before journals were added, we derive the keys from the transfers
+// within the stored Block instead.
+//
+// The Token entries cache the metadata of OEP4 tokens that were configured
+// with just their contract address, so that the currency is still known when
+// running in offline mode.
+//
+// The TokenRange entries record the heights that each OEP4 token has been
+// indexed for. Tokens that are added to the config after the store has started
+// indexing are backfilled in the background, by replaying the events for just
+// that contract, until they catch up with the indexed height. The tokens key is
+// set once the ranges are being tracked, so that the tokens of stores created
+// before then can be assumed to have been indexed from genesis.
+//
+// The staking key is set once the staking sub-accounts are being tracked. Stores
+// that were indexed before then are migrated by treating the missing staking
+// history as zero, and any resulting discrepancies are reported by Validate.
 
 // Store aggregates the blockchain data for Rosetta API calls.
 type Store struct {
 	db            *badger.DB
 	heightIndexed *int64
 	heightSynced  *int64
-	mu            sync.RWMutex // protects heightIndex, heightSynced
+	mu            sync.RWMutex // protects heightIndex, heightSynced, pending
+	offline       bool
+	pending       map[common.Address]bool
 	tokens        map[common.Address]*currencyInfo
 	parsedAbi     abi.ABI
 }
@@ -95,178 +157,148 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// IndexBlocks polls the node for new blocks and indexes the block data.
+// IndexBlocks indexes new blocks as soon as the node's ledger has saved them.
+// As a fallback for missed notifications, it also polls the node for new
+// blocks every cfg.WaitTime.
+//
+// Any OEP4 tokens that need to be backfilled are replayed in the background,
+// unless cfg.ExitEarly is set, in which case they are replayed before it
+// returns.
 func (s *Store) IndexBlocks(ctx context.Context, cfg IndexConfig) {
-	const debug = 0
-outer:
+	var backfilled chan struct{}
+	if !cfg.ExitEarly && len(s.pendingTokens()) > 0 {
+		backfilled = make(chan struct{})
+		go func() {
+			defer close(backfilled)
+			s.backfillTokens(ctx, cfg)
+		}()
+	}
+	done := func() {
+		if backfilled != nil {
+			<-backfilled
+		}
+		cfg.Done <- true
+	}
+	saved := make(chan struct{}, 1)
+	subscribeSaved(func(v interface{}) {
+		select {
+		case saved <- struct{}{}:
+		default:
+		}
+	})
+	poll := time.NewTimer(cfg.WaitTime)
+	defer poll.Stop()
 	for {
-		time.Sleep(cfg.WaitTime)
 		select {
 		case <-ctx.Done():
-			cfg.Done <- true
+			done()
 			return
-		default:
+		case <-saved:
+			if !poll.Stop() {
+				<-poll.C
+			}
+		case <-poll.C:
 		}
+		poll.Reset(cfg.WaitTime)
+		s.syncTokens()
 		height := s.getHeight()
 		if height > 0 {
 			height++
 		}
-		latest := actor.GetCurrentBlockHeight()
+		latest := chainHeight()
 		if cfg.ExitEarly && height == latest+1 {
+			s.backfillPending(ctx, cfg)
+			s.syncTokens()
 			return
 		}
-		for ; height <= latest; height++ {
-			select {
-			case <-ctx.Done():
-				cfg.Done <- true
-				return
-			default:
-			}
-			if height%100 == 0 {
-				log.Infof("Indexing block at height %d", height)
-			}
-			src, err := actor.GetBlockByHeight(height)
+		if !s.indexRange(ctx, cfg, height, latest) {
+			done()
+			return
+		}
+	}
+}
+
+// RollbackTo removes all indexed data for blocks above the given height within
+// a single atomic transaction. It errors if the height is above the indexed
+// height, or if the store was opened in offline mode.
+func (s *Store) RollbackTo(height uint32) error {
+	if s.offline {
+		return fmt.Errorf("services: the internal data store cannot be rolled back in offline mode")
+	}
+	current := s.getHeight()
+	if height > current {
+		return fmt.Errorf(
+			"services: cannot roll back to height %d above the indexed height %d",
+			height, current,
+		)
+	}
+	if height == current {
+		return nil
+	}
+	hval := make([]byte, 4)
+	binary.LittleEndian.PutUint32(hval, height)
+	err := s.db.Update(func(txn *badger.Txn) error {
+		seq, err := nextBlockEventSeq(txn)
+		if err != nil {
+			return err
+		}
+		for h := current; h > height; h-- {
+			keys, err := undoKeys(txn, h)
 			if err != nil {
-				log.Errorf("Failed to get block at height %d: %s", height, err)
-				continue outer
+				return err
 			}
-			var (
-				changes []*balanceChange
-				hashes  [][]byte
-			)
-			diffs := map[common.Address]map[common.Address]*big.Int{}
-			henc := lexinum.EncodeHeight(height)
-			hlen := len(henc)
-			id := &blockID{
-				hash:   src.Hash(),
-				height: height,
-			}
-			dst := &model.Block{
-				Timestamp: src.Header.Timestamp,
-			}
-			offsets := map[common.Uint256]int{}
-			for i, txn := range src.Transactions {
-				// NOTE(tav): We compute the unsigned transaction hash so that
-				// we can detect potential conflicts when generating nonces.
-				mut := &ctypes.MutableTransaction{
-					GasLimit: txn.GasLimit,
-					GasPrice: txn.GasPrice,
-					Nonce:    txn.Nonce,
-					Payer:    txn.Payer,
-					Payload:  txn.Payload,
-					TxType:   txn.TxType,
-					Version:  txn.Version,
-				}
-				mhash := mut.Hash()
-				hashes = append(hashes, mhash[:])
-				hash := txn.Hash()
-				dst.Transactions = append(dst.Transactions, &model.Transaction{
-					Hash: hash[:],
-				})
-				offsets[hash] = i
+			item, err := txn.Get(blockHeight2HashKey(h))
+			if err != nil {
+				return err
 			}
-			evts, err := actor.GetEventNotifyByHeight(height)
+			hash, err := item.ValueCopy(nil)
 			if err != nil {
-				if err != store.ErrNotFound {
-					log.Fatalf("Failed to get events at height %d: %s", height, err)
-				}
-				goto done
-			}
-			if evts == nil {
-				goto done
-			}
-			for _, info := range evts {
-				failed := info.State == event.CONTRACT_STATE_FAIL
-				gasVerified := false
-				offset := offsets[info.TxHash]
-				ori := src.Transactions[offset]
-				txn := dst.Transactions[offset]
-				txn.Failed = failed
-				for _, evt := range info.Notify {
-					_, ok := s.tokens[evt.ContractAddress]
-					if !ok {
-						continue
-					}
-					//check evm ong event log
-					var xfer *transfer
-					isEvm, eventLog := checkEvmEventLog(evt)
-					if isEvm {
-						xfer, err = parseEvmOngTransferLog(eventLog, s.parsedAbi, info.GasConsumed)
-						if err != nil {
-							log.Warnf("parse evm ong err:%s,height:%d,txhash:%s", err, height, info.TxHash.ToHexString())
-							continue
-						}
-					} else {
-						xfer = decodeTransfer(height, info, evt)
-						if xfer == nil {
-							log.Warnf(
-								"No transfer detected for state %#v in transaction %s at height %d",
-								evt.States, info.TxHash.ToHexString(), height)
-							continue
-						}
-					}
-					gasverified, isgas, isContinue := checkgasVerified(evt.ContractAddress, ori.Payer, xfer.from, failed, gasVerified, xfer.isGas)
-					if isContinue {
-						continue
-					}
-					gasVerified = gasverified
-					xfer.isGas = isgas
-					txn.Transfers = append(txn.Transfers, balanceCal(xfer, evt, diffs))
-				}
-				// NOTE(tav): We log the cases where a transfer event wasn't
-				// emitted for used gas.
-				if info.GasConsumed != 0 && !gasVerified {
-					log.Warnf(
-						"Missing gas fee transfer event for txn %s at height %d",
-						info.TxHash.ToHexString(), height,
-					)
-				}
+				return err
 			}
-			// Encode db keys for account balance changes.
-			for addr, accts := range diffs {
-				base := append([]byte{'a'}, addr2slice(addr)...)
-				lbase := len(base)
-				for contract, diff := range accts {
-					caddr := addr2slice(contract)
-					prefix := make([]byte, lbase+len(caddr))
-					n := copy(prefix, base)
-					copy(prefix[n:], caddr)
-					key := make([]byte, len(prefix)+hlen)
-					n = copy(key, prefix)
-					copy(key[n:], henc)
-					changes = append(changes, &balanceChange{
-						diff:   diff,
-						key:    key,
-						prefix: prefix,
-					})
+			keys = append(
+				keys,
+				blockKey(h),
+				blockHash2HeightKey(hash),
+				blockHeight2HashKey(h),
+				journalKey(h),
+			)
+			for _, key := range keys {
+				if err := txn.Delete(key); err != nil {
+					return err
 				}
 			}
-		done:
-			switch debug {
-			case 1:
-				for _, txn := range dst.Transactions {
-					if len(txn.Transfers) > 0 {
-						log.Infof("Indexing transaction %s at height %d", txn, height)
-					}
-				}
-			case 2:
-				log.Infof("Saving %s with %s at height %d", id, dst, height)
-			}
-			err = s.setBlock(&blockState{
-				block:   dst,
-				changes: changes,
-				hashes:  hashes,
-				id:      id,
-				synced:  latest,
-			})
-			if err != nil {
-				log.Errorf("Failed to store block at height %d: %s", height, err)
-				continue outer
+			if err := writeBlockEvent(txn, seq, h, hash, true); err != nil {
+				return err
 			}
+			seq++
+		}
+		if err := resetTokenRanges(txn, height); err != nil {
+			return err
+		}
+		return txn.Set([]byte("height"), hval)
+	})
+	if err != nil {
+		if err == badger.ErrTxnTooBig {
+			return fmt.Errorf(
+				"services: too much data to roll back from height %d to %d in one transaction",
+				current, height,
+			)
 		}
+		return fmt.Errorf(
+			"services: failed to roll back from height %d to %d: %s",
+			current, height, err,
+		)
 	}
+	indexed := int64(height)
+	s.mu.Lock()
+	s.heightIndexed = &indexed
+	s.mu.Unlock()
+	log.Infof("Rolled back internal data store from height %d to %d", current, height)
+	return nil
 }
 
+// Validate checks that the indexed balances match the on chain state, and
+// exits if they don't.
 func (s *Store) Validate() {
 	height := s.getHeight()
 	latest := actor.GetCurrentBlockHeight()
@@ -274,117 +306,122 @@ func (s *Store) Validate() {
 		log.Fatalf("Indexed height %d does not match latest synced block %d", height, latest)
 	}
 	log.Infof("Validating store at block height %d", height)
-	var accts []accountInfo
-	total := 0
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 1000
-		opts.Prefix = []byte("a")
-		opts.Reverse = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		var ident []byte
-		it.Seek([]byte("b"))
-		log.Info("Finding unique account/contract combinations")
-		for ; it.Valid(); it.Next() {
-			total++
-			key := it.Item().Key()
-			start := -1
-			switch key[1] {
-			case 1:
-				start = 3
-			case 0:
-				start = 22
-			default:
-				return fmt.Errorf("invalid account key found: %q", string(key))
-			}
-			end := -1
-			switch key[start] {
-			case 1:
-				end = start + 2
-			case 0:
-				end = start + 21
-			default:
-				return fmt.Errorf("invalid account key found: %q", string(key))
-			}
-			if bytes.Equal(key[:end], ident) {
-				continue
-			}
-			ident = make([]byte, end)
-			copy(ident, key)
-			ori := make([]byte, len(key))
-			copy(ori, key)
-			accts = append(accts, accountInfo{
-				acct:     decompressAddr(key[1:start]),
-				contract: decompressAddr(key[start:end]),
-				key:      ori,
-				native:   key[start] == 1,
-			})
+	if err := s.validateBalances(s.chainBalance); err != nil {
+		log.Fatalf("Unable to validate account balances: %s", err)
+	}
+	log.Infof("Successfully validated all balances")
+}
+
+// backfillPending replays the events for each pending OEP4 token up to the
+// current indexed height. It returns false if there are no pending tokens, or
+// if the context was cancelled.
+func (s *Store) backfillPending(ctx context.Context, cfg IndexConfig) bool {
+	contracts := s.pendingTokens()
+	if len(contracts) == 0 {
+		return false
+	}
+	for _, contract := range contracts {
+		if !s.backfillToken(ctx, cfg, contract) {
+			return false
 		}
-		return nil
+	}
+	return true
+}
+
+// backfillToken replays the events for the given OEP4 token, from the next
+// height in its TokenRange up to the current indexed height, committing them
+// in batches. It returns false if the context was cancelled.
+func (s *Store) backfillToken(ctx context.Context, cfg IndexConfig, contract common.Address) bool {
+	var rng *model.TokenRange
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		rng, err = getTokenRange(txn, contract)
+		return err
 	})
 	if err != nil {
-		log.Fatalf("Unable to calculate number of unique accounts: %s", err)
-	}
-	log.Infof("Found %d unique account/contract combinations out of %d", len(accts), total)
-	err = s.db.View(func(txn *badger.Txn) error {
-		var (
-			balance *big.Int
-			err     error
+		log.Errorf(
+			"Failed to read the indexed range for OEP4 token %s: %s",
+			contract.ToHexString(), err,
 		)
-		for i, info := range accts {
-			if i%100 == 0 {
-				log.Infof("Validated %d balances of %d", i, len(accts))
-			}
-			if info.native {
-				balance, err = chain.NativeBalanceOf(info.acct, info.contract)
-			} else {
-				balance, err = chain.BalanceOf(info.acct, info.contract)
-			}
-			if err != nil {
-				return fmt.Errorf(
-					"unable to get balanceOf account %s for %s (%d): %s",
-					info.acct.ToBase58(), info.contract.ToHexString(), i, err,
-				)
-			}
-			item, err := txn.Get(info.key)
-			if err != nil {
-				return fmt.Errorf(
-					"unable to get stored balance of account %s for %s (%d): %s",
-					info.acct.ToBase58(), info.contract.ToHexString(), i, err,
-				)
-			}
-			err = item.Value(func(val []byte) error {
-				amount := new(big.Int).SetBytes(val)
-				if amount.Cmp(balance) != 0 {
-					return fmt.Errorf(
-						"balance of account %s for %s (%d) does not match: stored (%s), on chain (%s)",
-						info.acct.ToBase58(), info.contract.ToHexString(), i, amount, balance,
-					)
-				}
-				return nil
-			})
-			if err != nil {
-				if info.native {
-					return err
-				}
-				log.Warnf("Validation failed for non-native OEP4 token: %s", err)
-			}
+		return true
+	}
+	end := s.getHeight()
+	if rng.Synced || rng.Next > end {
+		return true
+	}
+	var batch []*backfillState
+	start := rng.Next
+	for height := start; height <= end; height++ {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
 		}
-		return nil
-	})
-	if err != nil {
-		log.Fatalf("Unable to validate account balances: %s", err)
+		if height%10000 == 0 {
+			log.Infof(
+				"Backfilling OEP4 token %s at height %d",
+				contract.ToHexString(), height,
+			)
+		}
+		state, err := fetchTokenTransfers(height, contract)
+		if err != nil {
+			log.Errorf(
+				"Failed to get events for OEP4 token %s at height %d: %s",
+				contract.ToHexString(), height, err,
+			)
+			return true
+		}
+		if state != nil {
+			batch = append(batch, state)
+		}
+		if height-start+1 < uint32(cfg.BatchSize) && height < end {
+			continue
+		}
+		if err := s.setBackfill(contract, start, height, batch); err != nil {
+			log.Warnf(
+				"Failed to backfill OEP4 token %s at heights %d-%d: %s",
+				contract.ToHexString(), start, height, err,
+			)
+			return true
+		}
+		batch = nil
+		start = height + 1
+	}
+	return true
+}
+
+// backfillTokens replays the events for the pending OEP4 tokens in the
+// background, until all of them have been handed over to IndexBlocks, or the
+// context is cancelled.
+func (s *Store) backfillTokens(ctx context.Context, cfg IndexConfig) {
+	for s.backfillPending(ctx, cfg) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.WaitTime):
+		}
+	}
+}
+
+// chainBalance returns the on chain balance for the given account/contract
+// combination, or the staked balance if it has a peer.
+func (s *Store) chainBalance(info accountInfo) (*big.Int, error) {
+	token := s.tokens[info.contract]
+	switch {
+	case info.peer != nil:
+		return chain.StakedBalance(info.acct, info.peer)
+	case info.native:
+		return chain.NativeBalanceOf(info.acct, info.contract)
+	case token != nil && token.evm:
+		return chain.ERC20BalanceOf(info.acct, info.contract)
+	default:
+		return chain.BalanceOf(info.acct, info.contract)
 	}
-	log.Infof("Successfully validated all balances")
 }
 
 func (s *Store) checkUnsignedTxHash(hash common.Uint256) (bool, *types.Error) {
-	key := make([]byte, 33)
-	key[0] = 'e'
-	copy(key[1:], hash[:])
 	err := s.db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get(key)
+		_, err := txn.Get(txnHashKey(hash[:]))
 		return err
 	})
 	if err != nil {
@@ -396,6 +433,192 @@ func (s *Store) checkUnsignedTxHash(hash common.Uint256) (bool, *types.Error) {
 	return true, nil
 }
 
+// commitBlocks writes the given batch of blocks to the store, and returns
+// whether it succeeded. If the batch doesn't extend the indexed chain, any
+// orphaned blocks are rolled back.
+func (s *Store) commitBlocks(batch []*blockState) bool {
+	if len(batch) == 0 {
+		return true
+	}
+	first := batch[0]
+	last := batch[len(batch)-1]
+	err := s.setBlocks(batch)
+	if err == errParentMismatch {
+		log.Warnf(
+			"Blocks at heights %d-%d do not extend the indexed chain",
+			first.id.height, last.id.height,
+		)
+		if err := s.rewind(first.id.height - 1); err != nil {
+			log.Errorf("Failed to roll back orphaned blocks: %s", err)
+		}
+		return false
+	}
+	if err != nil {
+		log.Errorf(
+			"Failed to store blocks at heights %d-%d: %s",
+			first.id.height, last.id.height, err,
+		)
+		return false
+	}
+	return true
+}
+
+// fetchBlock gets the block at the given height from the node, and decodes it
+// into the state that needs to be written by setBlocks.
+func (s *Store) fetchBlock(height uint32, synced uint32) (*blockState, error) {
+	src, err := actor.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		changes []*balanceChange
+		hashes  [][]byte
+	)
+	diffs := map[common.Address]map[common.Address]*big.Int{}
+	staked := map[common.Address]map[string]*big.Int{}
+	henc := lexinum.EncodeHeight(height)
+	id := &blockID{
+		hash:   src.Hash(),
+		height: height,
+	}
+	parent := src.Header.PrevBlockHash
+	dst := &model.Block{
+		Timestamp: src.Header.Timestamp,
+	}
+	offsets := map[common.Uint256]int{}
+	for i, txn := range src.Transactions {
+		// NOTE(tav): We compute the unsigned transaction hash so that we can
+		// detect potential conflicts when generating nonces.
+		mut := &ctypes.MutableTransaction{
+			GasLimit: txn.GasLimit,
+			GasPrice: txn.GasPrice,
+			Nonce:    txn.Nonce,
+			Payer:    txn.Payer,
+			Payload:  txn.Payload,
+			TxType:   txn.TxType,
+			Version:  txn.Version,
+		}
+		mhash := mut.Hash()
+		hashes = append(hashes, mhash[:])
+		hash := txn.Hash()
+		mtxn := &model.Transaction{
+			Hash: hash[:],
+		}
+		// NOTE(tav): The unsigned hash only differs from the transaction hash
+		// for EIP-155 transactions, so we only store it in that case. It is
+		// needed to clean up the txnHashKey entries when rolling back a block.
+		if mhash != hash {
+			mtxn.UnsignedHash = mhash[:]
+		}
+		dst.Transactions = append(dst.Transactions, mtxn)
+		offsets[hash] = i
+	}
+	evts, err := actor.GetEventNotifyByHeight(height)
+	if err != nil {
+		if err != store.ErrNotFound {
+			log.Fatalf("Failed to get events at height %d: %s", height, err)
+		}
+		goto done
+	}
+	if evts == nil {
+		goto done
+	}
+	for _, info := range evts {
+		failed := info.State == event.CONTRACT_STATE_FAIL
+		gasVerified := false
+		offset := offsets[info.TxHash]
+		ori := src.Transactions[offset]
+		txn := dst.Transactions[offset]
+		txn.Failed = failed
+		// NOTE(tav): The contract call is only parsed for the successful
+		// transactions that need it, i.e. governance staking calls, and
+		// transfers that weren't signed by the sender, as these may have been
+		// made by a transferFrom call.
+		var (
+			inv    *chain.Invoke
+			parsed bool
+			stake  *stakingInvoke
+		)
+		if !failed && invokesGovernance(ori) {
+			inv, parsed = parseInvoke(ori), true
+			stake = decodeStaking(inv, info.TxHash)
+		}
+		for _, evt := range info.Notify {
+			_, ok := s.tokens[evt.ContractAddress]
+			if !ok || s.isPendingToken(evt.ContractAddress) {
+				continue
+			}
+			//check evm ong and erc-20 event log
+			var xfer *transfer
+			isEvm, eventLog := checkEvmEventLog(evt)
+			if isEvm {
+				xfer, err = parseEvmTransferLog(eventLog, s.parsedAbi, info.GasConsumed)
+				if err != nil {
+					log.Warnf("parse evm transfer err:%s,height:%d,txhash:%s", err, height, info.TxHash.ToHexString())
+					continue
+				}
+				if xfer == nil {
+					continue
+				}
+			} else {
+				xfer = decodeTransfer(height, info, evt)
+				if xfer == nil {
+					log.Warnf(
+						"No transfer detected for state %#v in transaction %s at height %d",
+						evt.States, info.TxHash.ToHexString(), height)
+					continue
+				}
+			}
+			gasverified, isgas, isContinue := checkgasVerified(evt.ContractAddress, ori.Payer, xfer.from, failed, gasVerified, xfer.isGas)
+			if isContinue {
+				continue
+			}
+			gasVerified = gasverified
+			xfer.isGas = isgas
+			if stake != nil && !xfer.isGas && !isEvm {
+				mxfers := stakingTransfers(stake, evt, xfer, diffs, staked)
+				if mxfers != nil {
+					txn.Transfers = append(txn.Transfers, mxfers...)
+					continue
+				}
+			}
+			mxfer := balanceCal(xfer, evt, diffs)
+			if !failed && !xfer.isGas && !isEvm && !signedBy(ori, xfer.from) {
+				if !parsed {
+					inv, parsed = parseInvoke(ori), true
+				}
+				mxfer.Spender = transferSpender(inv, evt.ContractAddress, xfer)
+			}
+			txn.Transfers = append(txn.Transfers, mxfer)
+		}
+		// NOTE(tav): Unstaking doesn't move any ONT, so there are no transfer
+		// events for it.
+		if stake != nil && stake.typ == opUnstake {
+			for i := range stake.peers {
+				txn.Transfers = append(txn.Transfers, stakingTransfer(stake, i, stake.acct, stake.acct))
+			}
+		}
+		// NOTE(tav): We log the cases where a transfer event wasn't emitted for
+		// used gas.
+		if info.GasConsumed != 0 && !gasVerified {
+			log.Warnf(
+				"Missing gas fee transfer event for txn %s at height %d",
+				info.TxHash.ToHexString(), height,
+			)
+		}
+	}
+	changes = append(balanceChanges(diffs, henc), stakedChanges(staked, henc)...)
+done:
+	return &blockState{
+		block:   dst,
+		changes: changes,
+		hashes:  hashes,
+		id:      id,
+		parent:  parent,
+		synced:  synced,
+	}, nil
+}
+
 func (s *Store) getBalance(
 	pid *types.PartialBlockIdentifier,
 	acct common.Address,
@@ -425,6 +648,15 @@ func (s *Store) getBalance(
 				continue
 			}
 		}
+		if s.isPendingToken(contract) {
+			return nil, wrapErr(
+				errCurrencyUnavailable,
+				fmt.Errorf(
+					"services: %s is still being backfilled",
+					contract.ToHexString(),
+				),
+			)
+		}
 		balance := &big.Int{}
 		prefix := accountKeyPrefix(addr2slice(acct), addr2slice(contract))
 		key := make([]byte, len(prefix)+len(info.hval))
@@ -463,6 +695,86 @@ func (s *Store) getBalance(
 	}, nil
 }
 
+// getBlockEvents returns up to limit block events starting from the given
+// sequence number, along with the maximum sequence number. If start is nil,
+// the most recent events are returned.
+func (s *Store) getBlockEvents(start *int64, limit int64) ([]*types.BlockEvent, int64, *types.Error) {
+	var (
+		events []*types.BlockEvent
+		max    int64
+	)
+	err := s.db.View(func(txn *badger.Txn) error {
+		next, err := nextBlockEventSeq(txn)
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			return nil
+		}
+		max = int64(next) - 1
+		seq := int64(0)
+		if start != nil {
+			seq = *start
+		} else if next > uint64(limit) {
+			seq = int64(next) - limit
+		}
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix: []byte{'m'},
+		})
+		defer it.Close()
+		for it.Seek(blockEventKey(uint64(seq))); it.Valid() && int64(len(events)) < limit; it.Next() {
+			item := it.Item()
+			evt := &model.BlockEvent{}
+			err := item.Value(func(val []byte) error {
+				return proto.Unmarshal(val, evt)
+			})
+			if err != nil {
+				return err
+			}
+			hash, err := common.Uint256ParseFromBytes(evt.Hash)
+			if err != nil {
+				return err
+			}
+			typ := types.ADDED
+			if evt.Removed {
+				typ = types.REMOVED
+			}
+			events = append(events, &types.BlockEvent{
+				BlockIdentifier: &types.BlockIdentifier{
+					Hash:  hash.ToHexString(),
+					Index: int64(evt.Height),
+				},
+				Sequence: int64(binary.BigEndian.Uint64(item.Key()[1:])),
+				Type:     typ,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		if err == badger.ErrConflict {
+			return nil, 0, errDatastoreConflict
+		}
+		log.Errorf("Unexpected error fetching block events from store: %s", err)
+		return nil, 0, wrapErr(errDatastore, err)
+	}
+	return events, max, nil
+}
+
+func (s *Store) getBlockHash(height uint32) (common.Uint256, error) {
+	hash := common.Uint256{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockHeight2HashKey(height))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			copy(hash[:], val)
+			return nil
+		})
+	})
+	return hash, err
+}
+
 func (s *Store) getBlockID(pid *types.PartialBlockIdentifier, nullable bool) (*blockID, *types.Error) {
 	if nullable {
 		if pid == nil || (pid.Hash == nil && pid.Index == nil) {
@@ -622,162 +934,847 @@ func (s *Store) getHeight() uint32 {
 	return uint32(*s.heightIndexed)
 }
 
-func (s *Store) setBlock(state *blockState) error {
-	blockKey := blockKey(state.id.height)
-	blockData, err := proto.Marshal(state.block)
-	if err != nil {
-		return fmt.Errorf("services: failed to encode model.Block: %s", err)
+// getStakedBalance returns the ONT that an account has staked with the given
+// consensus node. If peer is nil, the total staked across all nodes is
+// returned.
+func (s *Store) getStakedBalance(
+	pid *types.PartialBlockIdentifier,
+	acct common.Address,
+	currencies []*types.Currency,
+	peer []byte,
+) (*types.AccountBalanceResponse, *types.Error) {
+	info, xerr := s.getBlockInfo(pid, false)
+	if xerr != nil {
+		return nil, xerr
 	}
-	hashKey := blockHash2HeightKey(state.id.hash[:])
-	heightKey := blockHeight2HashKey(state.id.height)
-	hval := make([]byte, 4)
-	binary.LittleEndian.PutUint32(hval, state.id.height)
-	err = s.db.Update(func(txn *badger.Txn) error {
-		// Update account balances.
-		for _, acct := range state.changes {
-			prev := &big.Int{}
-			it := txn.NewIterator(badger.IteratorOptions{
-				Reverse: true,
-			})
-			defer it.Close()
-			it.Seek(acct.key)
-			var item *badger.Item
-			if it.ValidForPrefix(acct.prefix) {
-				exists := true
-				item = it.Item()
-				if bytes.Equal(acct.key, item.Key()) {
-					it.Next()
-					if it.ValidForPrefix(acct.prefix) {
-						item = it.Item()
-					} else {
-						exists = false
-					}
-				}
-				if exists {
-					err := item.Value(func(val []byte) error {
-						prev.SetBytes(val)
-						return nil
-					})
-					if err != nil {
-						return err
-					}
-				}
+	cinfo, xerr := s.getCurrencyInfo(ontAddr)
+	if xerr != nil {
+		return nil, xerr
+	}
+	balances := []*types.Amount{}
+	if len(currencies) > 0 {
+		found := false
+		for _, currency := range currencies {
+			info, xerr := s.validateCurrency(currency)
+			if xerr != nil {
+				return nil, xerr
+			}
+			if info == cinfo {
+				found = true
+			}
+		}
+		if !found {
+			return &types.AccountBalanceResponse{
+				Balances:        balances,
+				BlockIdentifier: info.blockID,
+			}, nil
+		}
+	}
+	base := accountKeyPrefix(addr2slice(acct), nil)
+	prefix := append(append([]byte{}, base...), stakingKey(peer)...)
+	peerEnd := len(base) + len(stakingKey(make([]byte, peerPubkeySize)))
+	latest := map[string]*big.Int{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix: prefix,
+		})
+		defer it.Close()
+		// NOTE(tav): Each peer has its own balance history, so we keep the
+		// last value at or below the requested height for each of them.
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := item.Key()
+			if len(key) <= peerEnd || bytes.Compare(key[peerEnd:], info.hval) > 0 {
+				continue
 			}
-			balance := prev.Add(prev, acct.diff).Bytes()
-			if err := txn.Set(acct.key, balance); err != nil {
+			balance := &big.Int{}
+			err := item.Value(func(val []byte) error {
+				balance.SetBytes(val)
+				return nil
+			})
+			if err != nil {
 				return err
 			}
+			latest[string(key[len(base):peerEnd])] = balance
 		}
-		// Write block metadata.
-		if err := txn.Set(blockKey, blockData); err != nil {
+		return nil
+	})
+	if err != nil {
+		log.Errorf(
+			"Unexpected error fetching staked balance for %s at %d from store: %s",
+			acct.ToBase58(), info.height, err,
+		)
+		return nil, wrapErr(errDatastore, err)
+	}
+	total := &big.Int{}
+	for _, balance := range latest {
+		total.Add(total, balance)
+	}
+	balances = append(balances, &types.Amount{
+		Currency: cinfo.currency,
+		Value:    total.String(),
+	})
+	return &types.AccountBalanceResponse{
+		Balances:        balances,
+		BlockIdentifier: info.blockID,
+	}, nil
+}
+
+// getTransaction looks up an indexed transaction by its hash, and returns it
+// along with the info for the block that contains it.
+func (s *Store) getTransaction(hash common.Uint256) (*blockInfo, *model.Transaction, *types.Error) {
+	var (
+		height uint32
+		offset uint32
+	)
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(txnLocationKey(hash[:]))
+		if err != nil {
 			return err
 		}
-		if err := txn.Set(hashKey, hval); err != nil {
-			return err
+		return item.Value(func(val []byte) error {
+			height = binary.LittleEndian.Uint32(val)
+			offset = binary.LittleEndian.Uint32(val[4:])
+			return nil
+		})
+	})
+	if err != nil {
+		switch err {
+		case badger.ErrConflict:
+			return nil, nil, errDatastoreConflict
+		case badger.ErrKeyNotFound:
+			return nil, nil, errUnknownTransactionHash
+		}
+		log.Errorf(
+			"Unexpected error fetching location of transaction %s from store: %s",
+			hash.ToHexString(), err,
+		)
+		return nil, nil, wrapErr(errDatastore, err)
+	}
+	info, xerr := s.getBlockInfoRaw(&blockID{
+		byHeight: true,
+		height:   height,
+	}, true)
+	if xerr != nil {
+		return nil, nil, xerr
+	}
+	txns := info.block.Transactions
+	if offset >= uint32(len(txns)) || !bytes.Equal(txns[offset].Hash, hash[:]) {
+		log.Errorf(
+			"Consistency failure when looking up transaction %s at block %d",
+			hash.ToHexString(), height,
+		)
+		return nil, nil, errDatastoreConsistency
+	}
+	return info, txns[offset], nil
+}
+
+// indexRange indexes the blocks from the start height up to and including the
+// end height. Blocks are fetched and decoded by a pool of workers ahead of time,
+// and are committed in height order, in batches of up to cfg.BatchSize blocks
+// while catching up, and one at a time near the tip. It returns false if the
+// context was cancelled.
+func (s *Store) indexRange(ctx context.Context, cfg IndexConfig, start uint32, end uint32) bool {
+	const debug = 0
+	if start > end {
+		return true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// When catching up, consecutive blocks are committed together within a
+	// single transaction, bounded to half of Badger's transaction limits. Near
+	// the tip, each block is committed by itself.
+	var (
+		batch []*blockState
+		count int64
+		size  int64
+	)
+	maxCount := s.db.MaxBatchCount() / 2
+	maxSize := s.db.MaxBatchSize() / 2
+	pending := s.prefetchBlocks(ctx, cfg, start, end)
+	for height := start; height <= end; height++ {
+		var result chan *fetchResult
+		select {
+		case <-ctx.Done():
+			return false
+		case result = <-pending:
+		}
+		var res *fetchResult
+		select {
+		case <-ctx.Done():
+			return false
+		case res = <-result:
+		}
+		if height%100 == 0 {
+			log.Infof("Indexing block at height %d", height)
+		}
+		if res.err != nil {
+			log.Errorf("Failed to get block at height %d: %s", height, res.err)
+			s.commitBlocks(batch)
+			return true
+		}
+		state := res.state
+		switch debug {
+		case 1:
+			for _, txn := range state.block.Transactions {
+				if len(txn.Transfers) > 0 {
+					log.Infof("Indexing transaction %s at height %d", txn, height)
+				}
+			}
+		case 2:
+			log.Infof("Saving %s with %s at height %d", state.id, state.block, height)
+		}
+		n, sz := state.writeSize()
+		if len(batch) > 0 && (count+n > maxCount || size+sz > maxSize) {
+			if !s.commitBlocks(batch) {
+				return true
+			}
+			batch, count, size = nil, 0, 0
+		}
+		batch = append(batch, state)
+		count += n
+		size += sz
+		if len(batch) < cfg.BatchSize && end-height >= uint32(cfg.BatchSize) {
+			continue
+		}
+		if !s.commitBlocks(batch) {
+			return true
 		}
-		if err := txn.Set(heightKey, state.id.hash[:]); err != nil {
+		batch, count, size = nil, 0, 0
+	}
+	return true
+}
+
+// initTokens records the heights that each OEP4 token in the config is
+// indexed for, and marks the tokens that need to be backfilled as pending.
+// Tokens that have been removed from the config are recorded as having been
+// indexed up to the current height, so that they can resume from there if they
+// are added back.
+func (s *Store) initTokens(oep4 []*OEP4Token) error {
+	height := s.getHeight()
+	configured := map[common.Address]bool{}
+	err := s.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("tokens"))
+		if err != nil && err != badger.ErrKeyNotFound {
 			return err
 		}
-		for _, hash := range state.hashes {
-			key := make([]byte, 33)
-			key[0] = 'e'
-			copy(key[1:], hash)
-			if err := txn.Set(key, []byte{}); err != nil {
+		// NOTE(tav): A new token can be indexed along with the blocks if
+		// nothing has been indexed yet, and the tokens of stores created before
+		// the ranges were tracked are assumed to be complete.
+		fresh := s.heightIndexed == nil || err == badger.ErrKeyNotFound
+		for _, token := range oep4 {
+			configured[token.Contract] = true
+			rng, err := getTokenRange(txn, token.Contract)
+			switch {
+			case err == badger.ErrKeyNotFound:
+				rng = &model.TokenRange{Synced: true}
+				if !fresh {
+					rng = &model.TokenRange{
+						Next:  token.StartHeight,
+						Start: token.StartHeight,
+					}
+					log.Infof(
+						"Backfilling new OEP4 token %s from height %d",
+						token.Contract.ToHexString(), token.StartHeight,
+					)
+				}
+				if err := setTokenRange(txn, token.Contract, rng); err != nil {
+					return err
+				}
+			case err != nil:
 				return err
 			}
+			if !rng.Synced {
+				s.pending[token.Contract] = true
+			}
 		}
-		return txn.Set([]byte("height"), hval)
+		removed := map[common.Address]*model.TokenRange{}
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix: []byte{'o'},
+		})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			contract, err := common.AddressParseFromBytes(it.Item().Key()[1:])
+			if err != nil {
+				return err
+			}
+			if configured[contract] {
+				continue
+			}
+			rng := &model.TokenRange{}
+			err = it.Item().Value(func(val []byte) error {
+				return proto.Unmarshal(val, rng)
+			})
+			if err != nil {
+				return err
+			}
+			if rng.Synced {
+				removed[contract] = rng
+			}
+		}
+		for contract, rng := range removed {
+			rng.Next = height + 1
+			rng.Synced = false
+			if err := setTokenRange(txn, contract, rng); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte("tokens"), []byte{})
 	})
 	if err != nil {
-		return err
+		return fmt.Errorf(
+			"services: failed to initialize the indexed ranges for OEP4 tokens: %s",
+			err,
+		)
 	}
-	s.setHeight(int64(state.id.height), int64(state.synced))
 	return nil
 }
 
-func (s *Store) setHeight(indexed int64, synced int64) {
-	s.mu.Lock()
-	s.heightIndexed = &indexed
-	s.heightSynced = &synced
-	s.mu.Unlock()
+// isPendingToken returns whether the given contract is an OEP4 token that is
+// still being backfilled.
+func (s *Store) isPendingToken(contract common.Address) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pending[contract]
 }
 
-func (s *Store) syncStatus() *types.SyncStatus {
+// pendingTokens returns the contracts of the OEP4 tokens that are still being
+// backfilled.
+func (s *Store) pendingTokens() []common.Address {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	synced := false
-	if s.heightSynced != nil {
-		synced = *s.heightIndexed == *s.heightSynced
-	}
-	return &types.SyncStatus{
-		CurrentIndex: s.heightIndexed,
-		Synced:       &synced,
-		TargetIndex:  s.heightSynced,
+	contracts := make([]common.Address, 0, len(s.pending))
+	for contract := range s.pending {
+		contracts = append(contracts, contract)
 	}
+	return contracts
 }
 
-// NOTE(tav): This function must return the exact pointer as a registered
-// currency in s.tokens, as it will be used for map lookups.
-func (s *Store) validateCurrency(c *types.Currency) (*currencyInfo, *types.Error) {
-	if c == nil || c.Metadata == nil {
-		return nil, invalidCurrencyf("currency.metadata field missing")
-	}
-	addr, ok := c.Metadata["contract"]
-	if !ok {
-		return nil, invalidCurrencyf("currency.metadata.contract field missing")
+// prefetchBlocks starts fetching the blocks from the start height up to and
+// including the end height using cfg.Workers goroutines. Result channels are
+// sent on the returned channel in height order, with at most cfg.Prefetch
+// blocks being fetched ahead of the one currently being read.
+func (s *Store) prefetchBlocks(ctx context.Context, cfg IndexConfig, start uint32, end uint32) <-chan chan *fetchResult {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
 	}
-	raw, ok := addr.(string)
-	if !ok {
-		return nil, invalidCurrencyf("currency.metadata.contract is not string")
-	}
-	contract, err := common.AddressFromHexString(raw)
-	if err != nil {
-		return nil, invalidCurrencyf(
-			"unable to parse currency.metadata.contract: %s", err,
-		)
+	prefetch := cfg.Prefetch
+	if prefetch < workers {
+		prefetch = workers
 	}
-	info, ok := s.tokens[contract]
-	if !ok {
-		return nil, wrapErr(
-			errCurrencyNotDefined,
-			fmt.Errorf("services: %s is not defined as a currency", raw),
-		)
-	}
-	if info.currency.Decimals != c.Decimals {
-		return nil, invalidCurrencyf(
-			"mismatching decimals value for currency: expected %d, got %d",
-			info.currency.Decimals, c.Decimals,
-		)
+	jobs := make(chan *fetchJob)
+	pending := make(chan chan *fetchResult, prefetch)
+	go func() {
+		defer close(jobs)
+		for height := start; height <= end; height++ {
+			result := make(chan *fetchResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case pending <- result:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- &fetchJob{height: height, result: result}:
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				state, err := blockFetcher(s, job.height, end)
+				job.result <- &fetchResult{
+					err:   err,
+					state: state,
+				}
+			}
+		}()
 	}
-	if info.currency.Symbol != c.Symbol {
-		return nil, invalidCurrencyf(
-			"mismatching symbol for currency: expected %q, got %q",
-			info.currency.Symbol, c.Symbol,
-		)
+	return pending
+}
+
+// rewind rolls back the indexed blocks, starting from the given height, until
+// it reaches a block that is part of the node's current chain.
+func (s *Store) rewind(height uint32) error {
+	fork := height
+	for {
+		hash, err := s.getBlockHash(fork)
+		if err != nil {
+			return fmt.Errorf(
+				"services: failed to get indexed block hash at height %d: %s",
+				fork, err,
+			)
+		}
+		if hash == chainBlockHash(fork) {
+			break
+		}
+		if fork == 0 {
+			log.Fatalf("Indexed genesis block %s does not match the node's chain", hash.ToHexString())
+		}
+		log.Warnf("Found orphaned block %s at height %d", hash.ToHexString(), fork)
+		fork--
 	}
-	return info, nil
+	return s.RollbackTo(fork)
 }
 
-func NewStore(dir string, oep4 []*OEP4Token, offline bool) (*Store, error) {
-	tokens := map[common.Address]*currencyInfo{
-		ongAddr: {
-			contract: ongAddr,
-			currency: &types.Currency{
-				Decimals: constants.ONG_DECIMALS_V2,
-				Symbol:   constants.ONG_SYMBOL,
-				Metadata: map[string]interface{}{
-					"contract": ongAddr.ToHexString(),
-				},
-			},
-		},
-		ontAddr: {
-			contract: ontAddr,
-			currency: &types.Currency{
-				Decimals: constants.ONT_DECIMALS_V2,
-				Symbol:   constants.ONT_SYMBOL,
-				Metadata: map[string]interface{}{
-					"contract": ontAddr.ToHexString(),
-				},
+// searchTransactions returns the page of indexed transactions that match the
+// given query, most recent first, along with the total number of matches.
+func (s *Store) searchTransactions(q *searchQuery) ([]*searchResult, int64, *types.Error) {
+	var (
+		results []*searchResult
+		total   int64
+		xerr    *types.Error
+	)
+	err := s.db.View(func(txn *badger.Txn) error {
+		prefixes, byHash, exact := q.sources()
+		cursors := []*indexCursor{}
+		if byHash {
+			item, err := txn.Get(txnLocationKey(q.txhash[:]))
+			switch err {
+			case nil:
+				err = item.Value(func(val []byte) error {
+					height := binary.LittleEndian.Uint32(val)
+					offset := binary.LittleEndian.Uint32(val[4:])
+					if height <= q.maxBlock {
+						cursors = append(cursors, &indexCursor{
+							loc:   uint64(height)<<32 | uint64(offset),
+							valid: true,
+						})
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			case badger.ErrKeyNotFound:
+			default:
+				return err
+			}
+		}
+		seek := make([]byte, 8)
+		binary.BigEndian.PutUint32(seek, q.maxBlock)
+		binary.BigEndian.PutUint32(seek[4:], math.MaxUint32)
+		for _, prefix := range prefixes {
+			it := txn.NewIterator(badger.IteratorOptions{
+				Prefix:  prefix,
+				Reverse: true,
+			})
+			defer it.Close()
+			it.Seek(append(prefix, seek...))
+			cursor := &indexCursor{it: it}
+			cursor.load()
+			cursors = append(cursors, cursor)
+		}
+		var (
+			block   *model.Block
+			blockID *types.BlockIdentifier
+		)
+		for {
+			loc := uint64(0)
+			found := false
+			for _, cursor := range cursors {
+				if cursor.valid && (!found || cursor.loc > loc) {
+					loc = cursor.loc
+					found = true
+				}
+			}
+			if !found {
+				return nil
+			}
+			for _, cursor := range cursors {
+				if cursor.valid && cursor.loc == loc {
+					cursor.next()
+				}
+			}
+			page := total >= q.offset && len(results) < q.limit
+			if exact && !page {
+				total++
+				continue
+			}
+			height := uint32(loc >> 32)
+			offset := uint32(loc)
+			if blockID == nil || blockID.Index != int64(height) {
+				block = &model.Block{}
+				item, err := txn.Get(blockKey(height))
+				if err != nil {
+					return err
+				}
+				err = item.Value(func(val []byte) error {
+					return proto.Unmarshal(val, block)
+				})
+				if err != nil {
+					return err
+				}
+				item, err = txn.Get(blockHeight2HashKey(height))
+				if err != nil {
+					return err
+				}
+				hash := common.Uint256{}
+				err = item.Value(func(val []byte) error {
+					copy(hash[:], val)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				blockID = &types.BlockIdentifier{
+					Hash:  hash.ToHexString(),
+					Index: int64(height),
+				}
+			}
+			if offset >= uint32(len(block.Transactions)) {
+				log.Errorf(
+					"Consistency failure when searching transaction %d at block %d",
+					offset, height,
+				)
+				xerr = errDatastoreConsistency
+				return nil
+			}
+			src := block.Transactions[offset]
+			if !exact && !q.match(src) {
+				continue
+			}
+			if page {
+				results = append(results, &searchResult{
+					blockID: blockID,
+					height:  height,
+					txn:     src,
+				})
+			}
+			total++
+		}
+	})
+	if xerr != nil {
+		return nil, 0, xerr
+	}
+	if err != nil {
+		if err == badger.ErrConflict {
+			return nil, 0, errDatastoreConflict
+		}
+		log.Errorf("Unexpected error searching transactions in store: %s", err)
+		return nil, 0, wrapErr(errDatastore, err)
+	}
+	return results, total, nil
+}
+
+// setBackfill writes the transfers for the given OEP4 token within the blocks
+// from the start height up to and including the end height, and advances its
+// TokenRange past them. It errors if the range was changed in the meantime,
+// e.g. by a rollback.
+func (s *Store) setBackfill(contract common.Address, start uint32, end uint32, states []*backfillState) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		rng, err := getTokenRange(txn, contract)
+		if err != nil {
+			return err
+		}
+		if rng.Synced || rng.Next != start {
+			return fmt.Errorf(
+				"services: indexed range for OEP4 token %s changed during backfill",
+				contract.ToHexString(),
+			)
+		}
+		for _, state := range states {
+			if err := writeBackfill(txn, state); err != nil {
+				return err
+			}
+		}
+		rng.Next = end + 1
+		return setTokenRange(txn, contract, rng)
+	})
+}
+
+func (s *Store) setBlock(state *blockState) error {
+	return s.setBlocks([]*blockState{state})
+}
+
+// setBlocks writes the given consecutive blocks within a single transaction,
+// and only moves the height key forward once all of them have been written.
+func (s *Store) setBlocks(states []*blockState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	last := states[len(states)-1]
+	hval := make([]byte, 4)
+	binary.LittleEndian.PutUint32(hval, last.id.height)
+	err := s.db.Update(func(txn *badger.Txn) error {
+		seq, err := nextBlockEventSeq(txn)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if err := writeBlock(txn, state); err != nil {
+				return err
+			}
+			err := writeBlockEvent(txn, seq, state.id.height, state.id.hash[:], false)
+			if err != nil {
+				return err
+			}
+			seq++
+		}
+		return txn.Set([]byte("height"), hval)
+	})
+	if err != nil {
+		return err
+	}
+	s.setHeight(int64(last.id.height), int64(last.synced))
+	return nil
+}
+
+func (s *Store) setHeight(indexed int64, synced int64) {
+	s.mu.Lock()
+	s.heightIndexed = &indexed
+	s.heightSynced = &synced
+	s.mu.Unlock()
+}
+
+func (s *Store) syncStatus() *types.SyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	synced := false
+	if s.heightSynced != nil {
+		synced = *s.heightIndexed == *s.heightSynced
+	}
+	return &types.SyncStatus{
+		CurrentIndex: s.heightIndexed,
+		Synced:       &synced,
+		TargetIndex:  s.heightSynced,
+	}
+}
+
+// syncTokens hands over the pending OEP4 tokens that have been backfilled up
+// to the indexed height to IndexBlocks. It must not be called while blocks are
+// being indexed, as it changes which events fetchBlock decodes.
+func (s *Store) syncTokens() {
+	height := s.getHeight()
+	for _, contract := range s.pendingTokens() {
+		synced := false
+		err := s.db.Update(func(txn *badger.Txn) error {
+			rng, err := getTokenRange(txn, contract)
+			if err != nil {
+				return err
+			}
+			if rng.Next != height+1 {
+				return nil
+			}
+			rng.Synced = true
+			synced = true
+			return setTokenRange(txn, contract, rng)
+		})
+		if err != nil {
+			log.Errorf(
+				"Failed to update the indexed range for OEP4 token %s: %s",
+				contract.ToHexString(), err,
+			)
+			continue
+		}
+		if !synced {
+			continue
+		}
+		s.mu.Lock()
+		delete(s.pending, contract)
+		s.mu.Unlock()
+		log.Infof(
+			"Finished backfilling OEP4 token %s at height %d",
+			contract.ToHexString(), height,
+		)
+	}
+}
+
+// validateBalances checks the latest indexed balance of every account/contract
+// combination, and every staked balance, against the given balanceOf func. It
+// only errors on mismatching native balances, and logs a warning otherwise.
+func (s *Store) validateBalances(balanceOf func(info accountInfo) (*big.Int, error)) error {
+	var accts []accountInfo
+	total := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = 1000
+		opts.Prefix = []byte("a")
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		var ident []byte
+		it.Seek([]byte("b"))
+		log.Info("Finding unique account/contract combinations")
+		for ; it.Valid(); it.Next() {
+			total++
+			key := it.Item().Key()
+			start := -1
+			switch key[1] {
+			case 1:
+				start = 3
+			case 0:
+				start = 22
+			default:
+				return fmt.Errorf("invalid account key found: %q", string(key))
+			}
+			end := -1
+			switch key[start] {
+			case 1:
+				end = start + 2
+			case 0:
+				end = start + 21
+			case 2:
+				end = start + 1 + peerPubkeySize
+			default:
+				return fmt.Errorf("invalid account key found: %q", string(key))
+			}
+			if len(key) <= end {
+				return fmt.Errorf("invalid account key found: %q", string(key))
+			}
+			if bytes.Equal(key[:end], ident) {
+				continue
+			}
+			ident = make([]byte, end)
+			copy(ident, key)
+			ori := make([]byte, len(key))
+			copy(ori, key)
+			info := accountInfo{
+				acct: decompressAddr(key[1:start]),
+				key:  ori,
+			}
+			if key[start] == 2 {
+				info.contract = ontAddr
+				info.peer = ori[start+1 : end]
+			} else {
+				info.contract = decompressAddr(key[start:end])
+				info.native = key[start] == 1
+			}
+			accts = append(accts, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("services: unable to calculate number of unique accounts: %s", err)
+	}
+	log.Infof("Found %d unique account/contract combinations out of %d", len(accts), total)
+	return s.db.View(func(txn *badger.Txn) error {
+		for i, info := range accts {
+			if i%100 == 0 {
+				log.Infof("Validated %d balances of %d", i, len(accts))
+			}
+			balance, err := balanceOf(info)
+			if err != nil {
+				return fmt.Errorf(
+					"unable to get balanceOf account %s for %s (%d): %s",
+					info.acct.ToBase58(), info.contract.ToHexString(), i, err,
+				)
+			}
+			item, err := txn.Get(info.key)
+			if err != nil {
+				return fmt.Errorf(
+					"unable to get stored balance of account %s for %s (%d): %s",
+					info.acct.ToBase58(), info.contract.ToHexString(), i, err,
+				)
+			}
+			err = item.Value(func(val []byte) error {
+				amount := new(big.Int).SetBytes(val)
+				if amount.Cmp(balance) != 0 {
+					if info.peer != nil {
+						return fmt.Errorf(
+							"staked balance of account %s with peer %x (%d) does not match: stored (%s), on chain (%s)",
+							info.acct.ToBase58(), info.peer, i, amount, balance,
+						)
+					}
+					return fmt.Errorf(
+						"balance of account %s for %s (%d) does not match: stored (%s), on chain (%s)",
+						info.acct.ToBase58(), info.contract.ToHexString(), i, amount, balance,
+					)
+				}
+				return nil
+			})
+			if err != nil {
+				if info.native {
+					return err
+				}
+				// NOTE(tav): Staked balances can also change without any
+				// transfers, e.g. when a node is penalised, so mismatches
+				// are only logged, as for non-native tokens.
+				log.Warnf("Validation failed for non-native balance: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+// NOTE(tav): This function must return the exact pointer as a registered
+// currency in s.tokens, as it will be used for map lookups.
+func (s *Store) validateCurrency(c *types.Currency) (*currencyInfo, *types.Error) {
+	if c == nil || c.Metadata == nil {
+		return nil, invalidCurrencyf("currency.metadata field missing")
+	}
+	addr, ok := c.Metadata["contract"]
+	if !ok {
+		return nil, invalidCurrencyf("currency.metadata.contract field missing")
+	}
+	raw, ok := addr.(string)
+	if !ok {
+		return nil, invalidCurrencyf("currency.metadata.contract is not string")
+	}
+	contract, err := parseContract(raw)
+	if err != nil {
+		return nil, invalidCurrencyf(
+			"unable to parse currency.metadata.contract: %s", err,
+		)
+	}
+	info, ok := s.tokens[contract]
+	if !ok {
+		return nil, wrapErr(
+			errCurrencyNotDefined,
+			fmt.Errorf("services: %s is not defined as a currency", raw),
+		)
+	}
+	if info.currency.Decimals != c.Decimals {
+		return nil, invalidCurrencyf(
+			"mismatching decimals value for currency: expected %d, got %d",
+			info.currency.Decimals, c.Decimals,
+		)
+	}
+	if info.currency.Symbol != c.Symbol {
+		return nil, invalidCurrencyf(
+			"mismatching symbol for currency: expected %q, got %q",
+			info.currency.Symbol, c.Symbol,
+		)
+	}
+	return info, nil
+}
+
+func NewStore(dir string, oep4 []*OEP4Token, erc20 []*ERC20Token, offline bool) (*Store, error) {
+	opts := badger.DefaultOptions(dir)
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("services: failed to open internal data store: %w", err)
+	}
+	for _, token := range oep4 {
+		if token.Symbol != "" {
+			continue
+		}
+		if err := loadOEP4Token(db, token, offline); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	tokens := map[common.Address]*currencyInfo{
+		ongAddr: {
+			contract: ongAddr,
+			currency: &types.Currency{
+				Decimals: constants.ONG_DECIMALS_V2,
+				Symbol:   constants.ONG_SYMBOL,
+				Metadata: map[string]interface{}{
+					"contract": ongAddr.ToHexString(),
+				},
+			},
+		},
+		ontAddr: {
+			contract: ontAddr,
+			currency: &types.Currency{
+				Decimals: constants.ONT_DECIMALS_V2,
+				Symbol:   constants.ONT_SYMBOL,
+				Metadata: map[string]interface{}{
+					"contract": ontAddr.ToHexString(),
+				},
 			},
 		},
 	}
@@ -794,15 +1791,29 @@ func NewStore(dir string, oep4 []*OEP4Token, offline bool) (*Store, error) {
 			wasm: token.Wasm,
 		}
 	}
-	opts := badger.DefaultOptions(dir)
-	db, err := badger.Open(opts)
-	if err != nil {
-		return nil, fmt.Errorf("services: failed to open internal data store: %w", err)
+	for _, token := range erc20 {
+		tokens[token.Contract] = &currencyInfo{
+			contract: token.Contract,
+			currency: &types.Currency{
+				Decimals: token.Decimals,
+				Symbol:   token.Symbol,
+				Metadata: map[string]interface{}{
+					"contract": ethcom.Address(token.Contract).Hex(),
+				},
+			},
+			evm: true,
+		}
 	}
+	parsedAbi, _ := abi.JSON(strings.NewReader(ERC20ABI))
 	if offline {
+		// NOTE(tav): The ABI is also needed in offline mode, so that EVM
+		// transactions can be constructed and parsed.
 		return &Store{
-			db:     db,
-			tokens: tokens,
+			db:        db,
+			offline:   true,
+			parsedAbi: parsedAbi,
+			pending:   map[common.Address]bool{},
+			tokens:    tokens,
 		}, nil
 	}
 	var indexed *int64
@@ -823,15 +1834,43 @@ func NewStore(dir string, oep4 []*OEP4Token, offline bool) (*Store, error) {
 			err,
 		)
 	}
+	// NOTE(tav): Stores indexed before the staking sub-accounts were tracked
+	// have no history for them, so we treat it as zero and only track the ONT
+	// staked from the currently indexed height onwards.
+	err = db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("staking"))
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+		if indexed != nil {
+			log.Warnf(
+				"Staked balances are only tracked from height %d, as the internal data store was indexed without them",
+				*indexed+1,
+			)
+		}
+		return txn.Set([]byte("staking"), []byte{})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf(
+			"services: failed to migrate the staking index of the internal data store: %s",
+			err,
+		)
+	}
 	synced := int64(actor.GetCurrentBlockHeight())
-	parsedAbi, _ := abi.JSON(strings.NewReader(ERC20ABI))
-	return &Store{
+	s := &Store{
 		db:            db,
 		heightIndexed: indexed,
 		heightSynced:  &synced,
+		pending:       map[common.Address]bool{},
 		tokens:        tokens,
 		parsedAbi:     parsedAbi,
-	}, nil
+	}
+	if err := s.initTokens(oep4); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
 func accountKeyPrefix(acct []byte, contract []byte) []byte {
@@ -839,6 +1878,26 @@ func accountKeyPrefix(acct []byte, contract []byte) []byte {
 	return append(key, contract...)
 }
 
+// addStakedDiff adds the given diff to the staked balance change of an account.
+func addStakedDiff(
+	staked map[common.Address]map[string]*big.Int,
+	acct common.Address,
+	peer []byte,
+	diff *big.Int,
+) {
+	peers, ok := staked[acct]
+	if !ok {
+		peers = map[string]*big.Int{}
+		staked[acct] = peers
+	}
+	balance, ok := peers[string(peer)]
+	if !ok {
+		balance = &big.Int{}
+		peers[string(peer)] = balance
+	}
+	balance.Add(balance, diff)
+}
+
 // Some of the native addresses like ONT/ONG are "compressed", and represented
 // by a leading "\x01" byte. Uncompressed addresses are represented by a leading
 // null byte.
@@ -857,6 +1916,17 @@ func addr2slice(addr common.Address) []byte {
 	}
 }
 
+func addressTxnsPrefix(acct []byte) []byte {
+	return append([]byte{'i'}, acct...)
+}
+
+func blockEventKey(seq uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = 'm'
+	binary.BigEndian.PutUint64(key[1:], seq)
+	return key
+}
+
 func blockHash2HeightKey(hash []byte) []byte {
 	key := make([]byte, 33)
 	key[0] = 'c'
@@ -878,6 +1948,145 @@ func blockKey(height uint32) []byte {
 	return key
 }
 
+func currencyTxnsPrefix(contract []byte) []byte {
+	return append([]byte{'j'}, contract...)
+}
+
+// decodeStaking returns the governance staking method called by the given
+// parsed invoke of the transaction with the given hash, if any.
+func decodeStaking(inv *chain.Invoke, hash common.Uint256) *stakingInvoke {
+	if inv == nil {
+		return nil
+	}
+	stake, err := decodeStakingInvoke(inv)
+	if err != nil {
+		log.Warnf(
+			"Ignoring staking call in txn %s: %s", hash.ToHexString(), err,
+		)
+		return nil
+	}
+	return stake
+}
+
+// decodeStakingInvoke converts a call to one of the governance contract's
+// staking methods. It returns nil if a different method was invoked.
+func decodeStakingInvoke(inv *chain.Invoke) (*stakingInvoke, error) {
+	if inv.Contract != govAddr || !inv.IsStaking() {
+		return nil, nil
+	}
+	stake := &stakingInvoke{
+		acct: inv.Stakes[0].Address,
+	}
+	switch inv.Method {
+	case "addInitPos", "authorizeForPeer", "authorizeForPeerTransferFrom",
+		"registerCandidate", "registerCandidateTransferFrom":
+		stake.typ = opStake
+	case "reduceInitPos", "unAuthorizeForPeer":
+		stake.typ = opUnstake
+	case "withdraw":
+		stake.typ = opWithdraw
+	case "withdrawFee":
+		stake.typ = opWithdrawFee
+		return stake, nil
+	default:
+		return nil, fmt.Errorf("services: unknown staking method: %s", inv.Method)
+	}
+	for _, src := range inv.Stakes {
+		if src.Address != stake.acct {
+			return nil, fmt.Errorf("services: mismatched %s addresses", inv.Method)
+		}
+		peer, err := hex.DecodeString(src.Peer)
+		if err != nil || len(peer) != peerPubkeySize {
+			return nil, fmt.Errorf("services: invalid peer public key %q", src.Peer)
+		}
+		if src.Amount.Sign() < 0 {
+			return nil, fmt.Errorf("services: negative %s amount: %s", inv.Method, src.Amount)
+		}
+		amount := (&big.Int{}).Mul(src.Amount, big.NewInt(constants.GWei))
+		stake.amounts = append(stake.amounts, amount)
+		stake.peers = append(stake.peers, peer)
+	}
+	return stake, nil
+}
+
+func journalKey(height uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = 'f'
+	binary.LittleEndian.PutUint32(key[1:], height)
+	return key
+}
+
+// loadOEP4Token fills in the metadata for an OEP4 token that was configured
+// with just its contract address. When online, the metadata is queried from the
+// contract and cached within the store. The cached metadata is used instead
+// when offline, or when the node hasn't synced the contract yet.
+func loadOEP4Token(db *badger.DB, token *OEP4Token, offline bool) error {
+	contract := token.Contract.ToHexString()
+	if !offline {
+		info, err := chain.VerifyOEP4(token.Contract, token.Wasm)
+		if err == nil {
+			if info.Symbol == "" {
+				return fmt.Errorf(
+					"services: empty symbol reported by OEP4 token %s", contract,
+				)
+			}
+			name, err := chain.OEP4Name(token.Contract, token.Wasm)
+			if err != nil {
+				return fmt.Errorf(
+					"services: failed to query name for OEP4 token %s: %s",
+					contract, err,
+				)
+			}
+			token.Decimals = info.Decimals
+			token.Name = name
+			token.Symbol = info.Symbol
+			log.Infof(
+				"Discovered OEP4 token %s: %s (%q) with %d decimals",
+				contract, token.Symbol, token.Name, token.Decimals,
+			)
+			return storeOEP4Token(db, token)
+		}
+		if err != chain.ErrNotDeployed {
+			return fmt.Errorf(
+				"services: failed to query metadata for OEP4 token %s: %s",
+				contract, err,
+			)
+		}
+	}
+	info := &model.Token{}
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(tokenKey(token.Contract))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return proto.Unmarshal(val, info)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		if offline {
+			return fmt.Errorf(
+				"services: no cached metadata for OEP4 token %s: run the server online first, or specify its decimals and symbol",
+				contract,
+			)
+		}
+		return fmt.Errorf(
+			"services: unable to query metadata for OEP4 token %s as its contract hasn't been synced yet: specify its decimals and symbol instead",
+			contract,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"services: failed to read cached metadata for OEP4 token %s: %s",
+			contract, err,
+		)
+	}
+	token.Decimals = info.Decimals
+	token.Name = info.Name
+	token.Symbol = info.Symbol
+	return nil
+}
+
 // Decode state ('transfer', from, to, amount) to a transfer struct.
 func decodeTransfer(height uint32, info *event.ExecuteNotify, evt *event.NotifyEventInfo) *transfer {
 	elems, ok := evt.States.([]interface{})
@@ -1094,31 +2303,34 @@ func checkEvmEventLog(evt *event.NotifyEventInfo) (bool, *ctypes.StorageLog) {
 	}
 	return true, ethLog
 }
-func parseEvmOngTransferLog(ethLog *ctypes.StorageLog, parsedAbi abi.ABI, gasConsumed uint64) (*transfer, error) {
-	ongLog := types2.Log{
+
+// parseEvmTransferLog decodes the ERC-20 Transfer event in an EVM log emitted
+// by the ONG contract or an ERC-20 token. It returns nil for other events, e.g.
+// Approval, that don't move any funds.
+func parseEvmTransferLog(ethLog *ctypes.StorageLog, parsedAbi abi.ABI, gasConsumed uint64) (*transfer, error) {
+	if len(ethLog.Topics) == 0 || ethLog.Topics[0] != parsedAbi.Events["Transfer"].ID {
+		return nil, nil
+	}
+	tokenLog := types2.Log{
 		Address: ethLog.Address,
 		Topics:  ethLog.Topics,
 		Data:    ethLog.Data,
 	}
-	if ongLog.Address == ONG_ADDR {
-		nbc := bind.NewBoundContract(ethcom.Address{}, parsedAbi, nil, nil, nil)
-		tf := new(ERC20Transfer)
-		err := nbc.UnpackLog(tf, "Transfer", ongLog)
-		if err != nil {
-			return nil, err
-		}
-		xfer := &transfer{
-			amount: tf.Value,
-			from:   common.Address(tf.From),
-			to:     common.Address(tf.To),
-		}
-		if tf.To == GOV_ADDR && tf.Value.Uint64()/constants.GWei == gasConsumed {
-			xfer.isGas = true
-		}
-		return xfer, nil
-	} else {
-		return nil, fmt.Errorf("parse evm ong transfer error")
+	nbc := bind.NewBoundContract(ethcom.Address{}, parsedAbi, nil, nil, nil)
+	tf := new(ERC20Transfer)
+	err := nbc.UnpackLog(tf, "Transfer", tokenLog)
+	if err != nil {
+		return nil, err
+	}
+	xfer := &transfer{
+		amount: tf.Value,
+		from:   common.Address(tf.From),
+		to:     common.Address(tf.To),
 	}
+	if tokenLog.Address == ONG_ADDR && tf.To == GOV_ADDR && tf.Value.Uint64()/constants.GWei == gasConsumed {
+		xfer.isGas = true
+	}
+	return xfer, nil
 }
 
 func balanceCal(xfer *transfer, evt *event.NotifyEventInfo, diffs map[common.Address]map[common.Address]*big.Int) *model.Transfer {
@@ -1161,56 +2373,286 @@ func balanceCal(xfer *transfer, evt *event.NotifyEventInfo, diffs map[common.Add
 	}
 }
 
-//return gasVerified,isGas,isContinue
-func checkgasVerified(contractAddress, payer, from common.Address, failed, gasVerified, isGas bool) (bool, bool, bool) {
-	if failed {
-		if contractAddress != ongAddr {
-			return false, false, true
+//return gasVerified,isGas,isContinue
+func checkgasVerified(contractAddress, payer, from common.Address, failed, gasVerified, isGas bool) (bool, bool, bool) {
+	if failed {
+		if contractAddress != ongAddr {
+			return false, false, true
+		}
+		// NOTE(tav): We skip additional transfer events on
+		// failed transactions if we've already matched the gas
+		// fee.
+		if gasVerified {
+			return false, false, true
+		}
+	}
+	if gasVerified {
+		return gasVerified, false, false
+	} else if isGas {
+		if payer == from {
+			return true, isGas, false
+		} else {
+			return gasVerified, false, false
+		}
+	}
+	return false, false, false
+}
+
+// balanceChanges encodes the db keys for the given account balance changes at
+// the encoded height.
+func balanceChanges(diffs map[common.Address]map[common.Address]*big.Int, henc []byte) []*balanceChange {
+	var changes []*balanceChange
+	for addr, accts := range diffs {
+		base := append([]byte{'a'}, addr2slice(addr)...)
+		lbase := len(base)
+		for contract, diff := range accts {
+			caddr := addr2slice(contract)
+			prefix := make([]byte, lbase+len(caddr))
+			n := copy(prefix, base)
+			copy(prefix[n:], caddr)
+			key := make([]byte, len(prefix)+len(henc))
+			n = copy(key, prefix)
+			copy(key[n:], henc)
+			changes = append(changes, &balanceChange{
+				diff:   diff,
+				key:    key,
+				prefix: prefix,
+			})
+		}
+	}
+	return changes
+}
+
+func decompressAddr(xs []byte) common.Address {
+	switch len(xs) {
+	case 2:
+		switch xs[1] {
+		case 2:
+			return ongAddr
+		case 1:
+			return ontAddr
+		case 7:
+			return govAddr
+		case 0:
+			return nullAddr
+		default:
+			panic(fmt.Errorf("invalid address to decompress: %q", string(xs)))
+		}
+	default:
+		addr, err := common.AddressParseFromBytes(xs[1:])
+		if err != nil {
+			panic(err)
+		}
+		return addr
+	}
+}
+
+// fetchTokenTransfers decodes the transfers of the given OEP4 token within the
+// block at the given height. It returns nil if there are none.
+func fetchTokenTransfers(height uint32, contract common.Address) (*backfillState, error) {
+	evts, err := actor.GetEventNotifyByHeight(height)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var (
+		src   *ctypes.Block
+		state *backfillState
+	)
+	diffs := map[common.Address]map[common.Address]*big.Int{}
+	invokes := map[int]*chain.Invoke{}
+	offsets := map[common.Uint256]int{}
+	for _, info := range evts {
+		// NOTE(tav): Only the ONG gas fee transfers of failed transactions
+		// are indexed.
+		if info.State == event.CONTRACT_STATE_FAIL {
+			continue
+		}
+		for _, evt := range info.Notify {
+			if evt.ContractAddress != contract {
+				continue
+			}
+			xfer := decodeTransfer(height, info, evt)
+			if xfer == nil {
+				log.Warnf(
+					"No transfer detected for state %#v in transaction %s at height %d",
+					evt.States, info.TxHash.ToHexString(), height)
+				continue
+			}
+			if src == nil {
+				src, err = actor.GetBlockByHeight(height)
+				if err != nil {
+					return nil, err
+				}
+				for i, txn := range src.Transactions {
+					offsets[txn.Hash()] = i
+				}
+				state = &backfillState{
+					hash:      src.Hash(),
+					height:    height,
+					transfers: map[int][]*model.Transfer{},
+				}
+			}
+			offset, ok := offsets[info.TxHash]
+			if !ok {
+				return nil, fmt.Errorf(
+					"services: unable to find transaction %s in block at height %d",
+					info.TxHash.ToHexString(), height,
+				)
+			}
+			mxfer := balanceCal(xfer, evt, diffs)
+			if ori := src.Transactions[offset]; !signedBy(ori, xfer.from) {
+				inv, ok := invokes[offset]
+				if !ok {
+					inv = parseInvoke(ori)
+					invokes[offset] = inv
+				}
+				mxfer.Spender = transferSpender(inv, contract, xfer)
+			}
+			state.transfers[offset] = append(state.transfers[offset], mxfer)
+		}
+	}
+	if state != nil {
+		state.changes = balanceChanges(diffs, lexinum.EncodeHeight(height))
+	}
+	return state, nil
+}
+
+func getTokenRange(txn *badger.Txn, contract common.Address) (*model.TokenRange, error) {
+	item, err := txn.Get(tokenRangeKey(contract))
+	if err != nil {
+		return nil, err
+	}
+	rng := &model.TokenRange{}
+	err = item.Value(func(val []byte) error {
+		return proto.Unmarshal(val, rng)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
+
+// invokesGovernance returns whether the payload of the given NeoVM transaction
+// references the governance contract, and could thus be a staking call.
+func invokesGovernance(txn *ctypes.Transaction) bool {
+	if txn.TxType != ctypes.InvokeNeo {
+		return false
+	}
+	invoke, ok := txn.Payload.(*payload.InvokeCode)
+	if !ok {
+		return false
+	}
+	return bytes.Contains(invoke.Code, govAddr[:])
+}
+
+// isClaim returns whether the transfer is a claim of unbound ONG, mirroring
+// the isClaim method on transferInfo.
+func isClaim(xfer *model.Transfer) bool {
+	return !xfer.IsGas &&
+		bytes.Equal(xfer.Contract, addr2slice(ongAddr)) &&
+		bytes.Equal(xfer.From, addr2slice(ontAddr))
+}
+
+func isNull(v []byte) bool {
+	return len(v) == 1 && v[0] == 0
+}
+
+// nextBlockEventSeq returns the sequence number for the next BlockEvent.
+func nextBlockEventSeq(txn *badger.Txn) (uint64, error) {
+	it := txn.NewIterator(badger.IteratorOptions{
+		Prefix:  []byte{'m'},
+		Reverse: true,
+	})
+	defer it.Close()
+	it.Seek(blockEventKey(math.MaxUint64))
+	if !it.Valid() {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(it.Item().Key()[1:]) + 1, nil
+}
+
+func opTypeTxnsPrefix(typ string) []byte {
+	key := append([]byte{'k'}, typ...)
+	return append(key, 0)
+}
+
+// parseInvoke returns the contract call made by the given NeoVM transaction,
+// or nil if it couldn't be parsed.
+func parseInvoke(txn *ctypes.Transaction) *chain.Invoke {
+	if txn.TxType != ctypes.InvokeNeo {
+		return nil
+	}
+	invoke, ok := txn.Payload.(*payload.InvokeCode)
+	if !ok {
+		return nil
+	}
+	// NOTE(tav): The code is only evaluated up to the point of the contract
+	// call, and chain.ParseInvoke bounds the number of steps, as failed
+	// transactions could loop forever.
+	inv, err := chain.ParseInvoke(invoke.Code)
+	if err != nil {
+		return nil
+	}
+	return inv
+}
+
+// resetTokenRanges moves the next height to backfill for each pending OEP4
+// token back to just after the given height, as the backfilled data above it
+// is removed along with the rest of the block when rolling back.
+func resetTokenRanges(txn *badger.Txn, height uint32) error {
+	reset := map[common.Address]*model.TokenRange{}
+	it := txn.NewIterator(badger.IteratorOptions{
+		Prefix: []byte{'o'},
+	})
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		rng := &model.TokenRange{}
+		err := it.Item().Value(func(val []byte) error {
+			return proto.Unmarshal(val, rng)
+		})
+		if err != nil {
+			return err
 		}
-		// NOTE(tav): We skip additional transfer events on
-		// failed transactions if we've already matched the gas
-		// fee.
-		if gasVerified {
-			return false, false, true
+		if rng.Synced || rng.Next <= height+1 {
+			continue
+		}
+		contract, err := common.AddressParseFromBytes(it.Item().Key()[1:])
+		if err != nil {
+			return err
 		}
+		rng.Next = height + 1
+		if rng.Next < rng.Start {
+			rng.Next = rng.Start
+		}
+		reset[contract] = rng
 	}
-	if gasVerified {
-		return gasVerified, false, false
-	} else if isGas {
-		if payer == from {
-			return true, isGas, false
-		} else {
-			return gasVerified, false, false
+	for contract, rng := range reset {
+		if err := setTokenRange(txn, contract, rng); err != nil {
+			return err
 		}
 	}
-	return false, false, false
+	return nil
 }
-func decompressAddr(xs []byte) common.Address {
-	switch len(xs) {
-	case 2:
-		switch xs[1] {
-		case 2:
-			return ongAddr
-		case 1:
-			return ontAddr
-		case 7:
-			return govAddr
-		case 0:
-			return nullAddr
-		default:
-			panic(fmt.Errorf("invalid address to decompress: %q", string(xs)))
-		}
-	default:
-		addr, err := common.AddressParseFromBytes(xs[1:])
-		if err != nil {
-			panic(err)
-		}
-		return addr
+
+func setTokenRange(txn *badger.Txn, contract common.Address, rng *model.TokenRange) error {
+	data, err := proto.Marshal(rng)
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.TokenRange: %s", err)
 	}
+	return txn.Set(tokenRangeKey(contract), data)
 }
 
-func isNull(v []byte) bool {
-	return len(v) == 1 && v[0] == 0
+// signedBy returns whether the given account signed the transaction.
+func signedBy(txn *ctypes.Transaction, acct common.Address) bool {
+	for _, addr := range txn.GetSignatureAddresses() {
+		if addr == acct {
+			return true
+		}
+	}
+	return false
 }
 
 func slice2addr(xs []byte) (common.Address, error) {
@@ -1251,6 +2693,482 @@ func slice2addr(xs []byte) (common.Address, error) {
 	}
 }
 
+// stakedChanges encodes the db keys for changes to staked balances.
+func stakedChanges(staked map[common.Address]map[string]*big.Int, henc []byte) []*balanceChange {
+	var changes []*balanceChange
+	for addr, peers := range staked {
+		base := accountKeyPrefix(addr2slice(addr), nil)
+		for peer, diff := range peers {
+			prefix := append(append([]byte{}, base...), stakingKey([]byte(peer))...)
+			key := make([]byte, len(prefix)+len(henc))
+			n := copy(key, prefix)
+			copy(key[n:], henc)
+			changes = append(changes, &balanceChange{
+				diff:   diff,
+				key:    key,
+				prefix: prefix,
+			})
+		}
+	}
+	return changes
+}
+
+// stakingKey returns the key suffix used for the ONT staked with the given
+// consensus node. If peer is nil, it returns the prefix for all staked
+// balances.
+func stakingKey(peer []byte) []byte {
+	return append([]byte{2}, peer...)
+}
+
+// stakingTransfer returns the transfer between the given accounts for the
+// stake at the given offset.
+func stakingTransfer(stake *stakingInvoke, offset int, from common.Address, to common.Address) *model.Transfer {
+	return &model.Transfer{
+		Amount:   stake.amounts[offset].Bytes(),
+		Contract: addr2slice(ontAddr),
+		From:     addr2slice(from),
+		Peer:     stake.peers[offset],
+		Staking:  stake.typ,
+		To:       addr2slice(to),
+	}
+}
+
+// stakingTransfers returns the transfers for the given transfer event if it
+// moves funds for the staking call, and updates the balance diffs accordingly.
+// The ONT balances change as for any other transfer, as the governance contract
+// holds the staked ONT on chain, and the staking sub-account of the staker is
+// updated alongside them. It returns nil if the event is unrelated to the
+// staking call.
+func stakingTransfers(
+	stake *stakingInvoke,
+	evt *event.NotifyEventInfo,
+	xfer *transfer,
+	diffs map[common.Address]map[common.Address]*big.Int,
+	staked map[common.Address]map[string]*big.Int,
+) []*model.Transfer {
+	switch stake.typ {
+	case opStake:
+		if evt.ContractAddress != ontAddr || xfer.from != stake.acct || xfer.to != govAddr {
+			return nil
+		}
+	case opWithdraw:
+		if evt.ContractAddress != ontAddr || xfer.from != govAddr || xfer.to != stake.acct {
+			return nil
+		}
+	case opWithdrawFee:
+		if evt.ContractAddress != ongAddr || xfer.from != govAddr || xfer.to != stake.acct {
+			return nil
+		}
+		mxfer := balanceCal(xfer, evt, diffs)
+		mxfer.Staking = opWithdrawFee
+		return []*model.Transfer{mxfer}
+	default:
+		return nil
+	}
+	if xfer.amount.Cmp(stake.total()) != 0 {
+		return nil
+	}
+	balanceCal(xfer, evt, diffs)
+	xfers := make([]*model.Transfer, len(stake.peers))
+	for i, peer := range stake.peers {
+		amount := stake.amounts[i]
+		if stake.typ == opWithdraw {
+			amount = (&big.Int{}).Neg(amount)
+		}
+		addStakedDiff(staked, stake.acct, peer, amount)
+		xfers[i] = stakingTransfer(stake, i, xfer.from, xfer.to)
+	}
+	return xfers
+}
+
+// storeOEP4Token caches the metadata for an OEP4 token within the store.
+func storeOEP4Token(db *badger.DB, token *OEP4Token) error {
+	enc, err := proto.Marshal(&model.Token{
+		Decimals: token.Decimals,
+		Name:     token.Name,
+		Symbol:   token.Symbol,
+	})
+	if err != nil {
+		return fmt.Errorf(
+			"services: failed to encode metadata for OEP4 token %s: %s",
+			token.Contract.ToHexString(), err,
+		)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set(tokenKey(token.Contract), enc)
+	})
+	if err != nil {
+		return fmt.Errorf(
+			"services: failed to cache metadata for OEP4 token %s: %s",
+			token.Contract.ToHexString(), err,
+		)
+	}
+	return nil
+}
+
+func tokenKey(contract common.Address) []byte {
+	return append([]byte{'n'}, contract[:]...)
+}
+
+func tokenRangeKey(contract common.Address) []byte {
+	return append([]byte{'o'}, contract[:]...)
+}
+
+func txnHashKey(hash []byte) []byte {
+	key := make([]byte, 33)
+	key[0] = 'e'
+	copy(key[1:], hash)
+	return key
+}
+
+// txnIndexKeys returns the secondary index keys for the transaction at the
+// given height and offset.
+func txnIndexKeys(height uint32, offset uint32, txn *model.Transaction) [][]byte {
+	loc := make([]byte, 8)
+	binary.BigEndian.PutUint32(loc, height)
+	binary.BigEndian.PutUint32(loc[4:], offset)
+	keys := [][]byte{append([]byte{'l'}, loc...)}
+	seen := map[string]bool{}
+	add := func(prefix []byte) {
+		key := append(prefix, loc...)
+		if seen[string(key)] {
+			return
+		}
+		seen[string(key)] = true
+		keys = append(keys, key)
+	}
+	for _, xfer := range txn.Transfers {
+		add(currencyTxnsPrefix(xfer.Contract))
+		for _, op := range transferOps(xfer) {
+			add(addressTxnsPrefix(op.acct))
+			add(opTypeTxnsPrefix(op.typ))
+		}
+	}
+	return keys
+}
+
+func txnLocationKey(hash []byte) []byte {
+	key := make([]byte, 33)
+	key[0] = 'g'
+	copy(key[1:], hash)
+	return key
+}
+
+// transferOps returns the account and operation type for each of the
+// operations that a transfer is transformed into, mirroring appendOperations.
+func transferOps(xfer *model.Transfer) []transferOp {
+	var ops []transferOp
+	null := addr2slice(nullAddr)
+	fromNull := bytes.Equal(xfer.From, null)
+	toNull := bytes.Equal(xfer.To, null)
+	xferType := opTransfer
+	switch {
+	case xfer.Staking == opUnstake:
+		return []transferOp{{acct: xfer.From, typ: opUnstake}}
+	case xfer.Staking != "":
+		xferType = xfer.Staking
+	case isClaim(xfer):
+		xferType = opClaimONG
+	case len(xfer.Spender) > 0:
+		xferType = opTransferFrom
+	}
+	if !fromNull {
+		typ := xferType
+		if toNull {
+			typ = opBurn
+		} else if xfer.IsGas {
+			typ = opGasFee
+		}
+		ops = append(ops, transferOp{acct: xfer.From, typ: typ})
+	}
+	if !toNull {
+		typ := xferType
+		if fromNull {
+			typ = opMint
+		} else if xfer.IsGas {
+			typ = opGasFee
+		}
+		ops = append(ops, transferOp{acct: xfer.To, typ: typ})
+	}
+	return ops
+}
+
+// transferSpender returns the spender for a transfer that was made by a
+// transferFrom call in the given parsed invoke, if any.
+func transferSpender(inv *chain.Invoke, contract common.Address, xfer *transfer) []byte {
+	if inv == nil || inv.Contract != contract {
+		return nil
+	}
+	if inv.Method != "transferFrom" && inv.Method != "transferFromV2" {
+		return nil
+	}
+	for _, src := range inv.Transfers {
+		if src.From == xfer.from && src.To == xfer.to {
+			return addr2slice(src.Payer)
+		}
+	}
+	return nil
+}
+
+// undoKeys returns the keys that need to be deleted, in addition to the block
+// metadata keys, in order to roll back the block at the given height.
+func undoKeys(txn *badger.Txn, height uint32) ([][]byte, error) {
+	journal := &model.Journal{}
+	item, err := txn.Get(journalKey(height))
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			return proto.Unmarshal(val, journal)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return journal.Keys, nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	// NOTE(tav): Blocks indexed before the journal was introduced don't have
+	// one, so we derive the keys from the transfers in the stored block.
+	block := &model.Block{}
+	item, err = txn.Get(blockKey(height))
+	if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		return proto.Unmarshal(val, block)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	henc := lexinum.EncodeHeight(height)
+	null := addr2slice(nullAddr)
+	for _, src := range block.Transactions {
+		for _, xfer := range src.Transfers {
+			for _, acct := range [][]byte{xfer.From, xfer.To} {
+				if bytes.Equal(acct, null) {
+					continue
+				}
+				prefix := accountKeyPrefix(acct, xfer.Contract)
+				key := make([]byte, len(prefix)+len(henc))
+				n := copy(key, prefix)
+				copy(key[n:], henc)
+				keys = append(keys, key)
+			}
+		}
+		mhash := src.UnsignedHash
+		if len(mhash) == 0 {
+			mhash = src.Hash
+		}
+		keys = append(keys, txnHashKey(mhash))
+	}
+	return keys, nil
+}
+
+// writeBackfill adds the backfilled transfers of an OEP4 token to an already
+// indexed block, along with their balance changes, secondary index entries,
+// and undo journal keys.
+func writeBackfill(txn *badger.Txn, state *backfillState) error {
+	item, err := txn.Get(blockHeight2HashKey(state.height))
+	if err != nil {
+		return err
+	}
+	err = item.Value(func(val []byte) error {
+		if !bytes.Equal(val, state.hash[:]) {
+			return fmt.Errorf(
+				"services: indexed block at height %d does not match the node's chain",
+				state.height,
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// NOTE(tav): The undo keys are derived before the transfers are added to
+	// the block, so that blocks without a journal get a complete one.
+	keys, err := undoKeys(txn, state.height)
+	if err != nil {
+		return err
+	}
+	block := &model.Block{}
+	item, err = txn.Get(blockKey(state.height))
+	if err != nil {
+		return err
+	}
+	err = item.Value(func(val []byte) error {
+		return proto.Unmarshal(val, block)
+	})
+	if err != nil {
+		return err
+	}
+	for _, acct := range state.changes {
+		if err := writeBalance(txn, acct); err != nil {
+			return err
+		}
+		keys = append(keys, acct.key)
+	}
+	for offset, mtxn := range block.Transactions {
+		xfers := state.transfers[offset]
+		if len(xfers) == 0 {
+			continue
+		}
+		mtxn.Transfers = append(mtxn.Transfers, xfers...)
+		for _, key := range txnIndexKeys(state.height, uint32(offset), mtxn) {
+			if err := txn.Set(key, []byte{}); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+	}
+	blockData, err := proto.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.Block: %s", err)
+	}
+	if err := txn.Set(blockKey(state.height), blockData); err != nil {
+		return err
+	}
+	journalData, err := proto.Marshal(&model.Journal{Keys: keys})
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.Journal: %s", err)
+	}
+	return txn.Set(journalKey(state.height), journalData)
+}
+
+// writeBalance adds the balance change to the account's most recent balance
+// below its height, and writes the result at its height.
+func writeBalance(txn *badger.Txn, acct *balanceChange) error {
+	prev := &big.Int{}
+	it := txn.NewIterator(badger.IteratorOptions{
+		Reverse: true,
+	})
+	defer it.Close()
+	it.Seek(acct.key)
+	var item *badger.Item
+	if it.ValidForPrefix(acct.prefix) {
+		exists := true
+		item = it.Item()
+		if bytes.Equal(acct.key, item.Key()) {
+			it.Next()
+			if it.ValidForPrefix(acct.prefix) {
+				item = it.Item()
+			} else {
+				exists = false
+			}
+		}
+		if exists {
+			err := item.Value(func(val []byte) error {
+				prev.SetBytes(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	balance := prev.Add(prev, acct.diff)
+	// NOTE(tav): Balances are stored as unsigned big-endian bytes, so a
+	// negative balance would be read back as a positive one. This can happen
+	// when ONT is withdrawn from stakes that predate the staking sub-accounts,
+	// so we clamp it to zero and leave Validate to report the mismatch.
+	if balance.Sign() < 0 {
+		log.Warnf(
+			"Clamping negative balance of %s for account key %x to zero",
+			balance, acct.key,
+		)
+		balance.SetInt64(0)
+	}
+	return txn.Set(acct.key, balance.Bytes())
+}
+
+// writeBlock writes the balance changes, metadata, and undo journal for the
+// given block within the given transaction. It does not update the height key.
+func writeBlock(txn *badger.Txn, state *blockState) error {
+	blockKey := blockKey(state.id.height)
+	blockData, err := proto.Marshal(state.block)
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.Block: %s", err)
+	}
+	hashKey := blockHash2HeightKey(state.id.hash[:])
+	heightKey := blockHeight2HashKey(state.id.height)
+	hval := make([]byte, 4)
+	binary.LittleEndian.PutUint32(hval, state.id.height)
+	journal := &model.Journal{}
+	// Check that the block extends the chain we've indexed so far.
+	if state.id.height > 0 {
+		item, err := txn.Get(blockHeight2HashKey(state.id.height - 1))
+		if err != nil {
+			return err
+		}
+		err = item.Value(func(val []byte) error {
+			if !bytes.Equal(val, state.parent[:]) {
+				return errParentMismatch
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Update account balances.
+	for _, acct := range state.changes {
+		if err := writeBalance(txn, acct); err != nil {
+			return err
+		}
+		journal.Keys = append(journal.Keys, acct.key)
+	}
+	// Write block metadata.
+	if err := txn.Set(blockKey, blockData); err != nil {
+		return err
+	}
+	if err := txn.Set(hashKey, hval); err != nil {
+		return err
+	}
+	if err := txn.Set(heightKey, state.id.hash[:]); err != nil {
+		return err
+	}
+	for _, hash := range state.hashes {
+		key := txnHashKey(hash)
+		if err := txn.Set(key, []byte{}); err != nil {
+			return err
+		}
+		journal.Keys = append(journal.Keys, key)
+	}
+	for offset, src := range state.block.Transactions {
+		key := txnLocationKey(src.Hash)
+		loc := make([]byte, 8)
+		binary.LittleEndian.PutUint32(loc, state.id.height)
+		binary.LittleEndian.PutUint32(loc[4:], uint32(offset))
+		if err := txn.Set(key, loc); err != nil {
+			return err
+		}
+		journal.Keys = append(journal.Keys, key)
+		for _, key := range txnIndexKeys(state.id.height, uint32(offset), src) {
+			if err := txn.Set(key, []byte{}); err != nil {
+				return err
+			}
+			journal.Keys = append(journal.Keys, key)
+		}
+	}
+	journalData, err := proto.Marshal(journal)
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.Journal: %s", err)
+	}
+	return txn.Set(journalKey(state.id.height), journalData)
+}
+
+func writeBlockEvent(txn *badger.Txn, seq uint64, height uint32, hash []byte, removed bool) error {
+	data, err := proto.Marshal(&model.BlockEvent{
+		Hash:    hash,
+		Height:  height,
+		Removed: removed,
+	})
+	if err != nil {
+		return fmt.Errorf("services: failed to encode model.BlockEvent: %s", err)
+	}
+	return txn.Set(blockEventKey(seq), data)
+}
+
 func init() {
 	// Enable the use of json.Number when decoding event state from the ledger.
 	ledgerstore.UseNumber = true
@@ -0,0 +1,1022 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethcom "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ontio/ontology-rosetta/chain"
+	"github.com/ontio/ontology-rosetta/lexinum"
+	"github.com/ontio/ontology-rosetta/model"
+	"github.com/ontio/ontology/common"
+	"github.com/ontio/ontology/core/payload"
+	ctypes "github.com/ontio/ontology/core/types"
+	"github.com/ontio/ontology/core/utils"
+	"github.com/ontio/ontology/smartcontract/event"
+)
+
+var testAcct = mustHexAddr("1100000000000000000000000000000000000000")
+
+func TestBlockEvents(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 4; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, 1)); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	if err := s.RollbackTo(1); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	if err := s.setBlock(testBlockState(2, 1, 1)); err != nil {
+		t.Fatalf("Failed to re-index block at height 2: %s", err)
+	}
+	expected := []string{
+		"0 block_added 0", "1 block_added 1", "2 block_added 2", "3 block_added 3",
+		"4 block_removed 3", "5 block_removed 2", "6 block_added 2",
+	}
+	for _, tc := range []struct {
+		start *int64
+		limit int64
+		want  []string
+	}{
+		{nil, 2, expected[5:]},
+		{new(int64), 100, expected},
+		{new(int64), 3, expected[:3]},
+	} {
+		events, max, xerr := s.getBlockEvents(tc.start, tc.limit)
+		if xerr != nil {
+			t.Fatalf("Failed to get block events: %s", xerr.Message)
+		}
+		if max != 6 {
+			t.Fatalf("Unexpected max sequence: got %d, want 6", max)
+		}
+		got := []string{}
+		for _, evt := range events {
+			got = append(got, fmt.Sprintf("%d %s %d", evt.Sequence, evt.Type, evt.BlockIdentifier.Index))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Fatalf("Unexpected block events: got %v, want %v", got, tc.want)
+		}
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 3; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, 1)); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	hash := common.Uint256{}
+	copy(hash[:], testTxnHash(1))
+	info, txn, xerr := s.getTransaction(hash)
+	if xerr != nil {
+		t.Fatalf("Failed to get transaction: %s", xerr.Message)
+	}
+	if info.height != 1 {
+		t.Fatalf("Unexpected block height for transaction: got %d, want 1", info.height)
+	}
+	if !bytes.Equal(txn.Hash, hash[:]) {
+		t.Fatalf("Unexpected transaction hash: got %x, want %x", txn.Hash, hash)
+	}
+	hash[1] = 0
+	if _, _, xerr := s.getTransaction(hash); xerr != errUnknownTransactionHash {
+		t.Fatalf("Expected errUnknownTransactionHash for an unknown hash, got: %v", xerr)
+	}
+}
+
+func TestOEP4TokenCache(t *testing.T) {
+	dir := t.TempDir()
+	contract := mustHexAddr("ff31ec74d01f7b7d45ed2add930f5d2239f7de33")
+	_, err := NewStore(dir, []*OEP4Token{{Contract: contract}}, nil, true)
+	if err == nil {
+		t.Fatalf("Expected an error for an OEP4 token without cached metadata")
+	}
+	s, err := NewStore(dir, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	err = storeOEP4Token(s.db, &OEP4Token{
+		Contract: contract,
+		Decimals: 9,
+		Name:     "Wing Token",
+		Symbol:   "WING",
+	})
+	if err != nil {
+		t.Fatalf("Failed to cache OEP4 token: %s", err)
+	}
+	s.Close()
+	token := &OEP4Token{Contract: contract}
+	s, err = NewStore(dir, []*OEP4Token{token}, nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create store with cached OEP4 token: %s", err)
+	}
+	defer s.Close()
+	if token.Decimals != 9 || token.Name != "Wing Token" || token.Symbol != "WING" {
+		t.Fatalf("Unexpected OEP4 token metadata: %+v", token)
+	}
+	info, xerr := s.getCurrencyInfo(contract)
+	if xerr != nil {
+		t.Fatalf("Failed to get currency info: %s", xerr.Message)
+	}
+	if info.currency.Decimals != 9 || info.currency.Symbol != "WING" {
+		t.Fatalf("Unexpected currency: %+v", info.currency)
+	}
+}
+
+func TestParseEvmTransferLog(t *testing.T) {
+	parsedAbi, err := abi.JSON(strings.NewReader(ERC20ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := ethcom.HexToAddress("0x5a6a9d3bd4c5ac1e9f4dbd2cbc0f6c0e4d6b8a83")
+	from := ethcom.Address(testAcct)
+	to := ethcom.HexToAddress("0x1200000000000000000000000000000000000000")
+	value := ethcom.LeftPadBytes(big.NewInt(1000).Bytes(), 32)
+	xfer, err := parseEvmTransferLog(&ctypes.StorageLog{
+		Address: token,
+		Topics: []ethcom.Hash{
+			parsedAbi.Events["Transfer"].ID,
+			ethcom.BytesToHash(from.Bytes()),
+			ethcom.BytesToHash(to.Bytes()),
+		},
+		Data: value,
+	}, parsedAbi, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse ERC-20 transfer log: %s", err)
+	}
+	if xfer == nil {
+		t.Fatal("Missing transfer for ERC-20 transfer log")
+	}
+	if xfer.from != testAcct || xfer.to != common.Address(to) {
+		t.Fatalf("Unexpected transfer addresses: from %s, to %s", xfer.from.ToHexString(), xfer.to.ToHexString())
+	}
+	if xfer.amount.Int64() != 1000 {
+		t.Fatalf("Unexpected transfer amount: got %s, want 1000", xfer.amount)
+	}
+	if xfer.isGas {
+		t.Fatal("Unexpected gas flag for ERC-20 transfer")
+	}
+	xfer, err = parseEvmTransferLog(&ctypes.StorageLog{
+		Address: token,
+		Topics: []ethcom.Hash{
+			ethcrypto.Keccak256Hash([]byte("Approval(address,address,uint256)")),
+			ethcom.BytesToHash(from.Bytes()),
+			ethcom.BytesToHash(to.Bytes()),
+		},
+		Data: value,
+	}, parsedAbi, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse ERC-20 approval log: %s", err)
+	}
+	if xfer != nil {
+		t.Fatalf("Unexpected transfer for ERC-20 approval log: %#v", xfer)
+	}
+}
+
+func TestReorg(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 4; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, int64(height+1))); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	// NOTE(tav): The node has switched to a fork after height 1, so the
+	// indexed blocks at heights 2 and 3 are orphaned.
+	orig := chainBlockHash
+	chainBlockHash = func(height uint32) common.Uint256 {
+		if height > 1 {
+			return common.Uint256{0xff, byte(height)}
+		}
+		return testBlockHash(height)
+	}
+	t.Cleanup(func() {
+		chainBlockHash = orig
+	})
+	fork := testBlockState(4, 4, 1)
+	fork.parent = common.Uint256{0xff, 3}
+	if s.commitBlocks([]*blockState{fork}) {
+		t.Fatalf("Expected the commit of a block with a mismatching parent to fail")
+	}
+	if height := s.getHeight(); height != 1 {
+		t.Fatalf("Unexpected height after reorg: got %d, want 1", height)
+	}
+	testBalance(t, s, "3")
+	prefix := accountKeyPrefix(addr2slice(testAcct), addr2slice(ontAddr))
+	for height := uint32(2); height < 4; height++ {
+		err := s.db.View(func(txn *badger.Txn) error {
+			for _, key := range [][]byte{
+				append(append([]byte{}, prefix...), lexinum.EncodeHeight(height)...),
+				blockKey(height),
+				blockHeight2HashKey(height),
+				journalKey(height),
+				txnHashKey(testTxnHash(height)),
+				txnLocationKey(testTxnHash(height)),
+			} {
+				if _, err := txn.Get(key); err != badger.ErrKeyNotFound {
+					t.Errorf("Found key %q after reorg: %v", key, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.commitBlocks([]*blockState{testBlockState(2, 1, 10)}) {
+		t.Fatalf("Failed to index block on the new fork at height 2")
+	}
+	testBalance(t, s, "13")
+}
+
+func TestRollbackTo(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 4; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, int64(height+1))); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	err := s.setBlock(testBlockState(4, 10, 1))
+	if err != errParentMismatch {
+		t.Fatalf("Expected errParentMismatch for an orphaned parent, got: %v", err)
+	}
+	if err := s.RollbackTo(1); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	if height := s.getHeight(); height != 1 {
+		t.Fatalf("Unexpected height after rollback: got %d, want 1", height)
+	}
+	testBalance(t, s, "3")
+	for height := uint32(2); height < 4; height++ {
+		err := s.db.View(func(txn *badger.Txn) error {
+			for _, key := range [][]byte{
+				blockKey(height),
+				blockHeight2HashKey(height),
+				journalKey(height),
+				txnHashKey(testTxnHash(height)),
+				txnLocationKey(testTxnHash(height)),
+			} {
+				if _, err := txn.Get(key); err != badger.ErrKeyNotFound {
+					t.Errorf("Found key %q after rollback: %v", key, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.setBlock(testBlockState(2, 1, 10)); err != nil {
+		t.Fatalf("Failed to re-index block at height 2: %s", err)
+	}
+	testBalance(t, s, "13")
+}
+
+func TestRollbackToHeight(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 4; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, int64(height+1))); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	if err := s.RollbackTo(10); err == nil {
+		t.Fatalf("Expected an error when rolling back to a height above the tip")
+	}
+	if height := s.getHeight(); height != 3 {
+		t.Fatalf("Unexpected height after a rejected rollback: got %d, want 3", height)
+	}
+	if err := s.RollbackTo(1); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	if height := s.getHeight(); height != 1 {
+		t.Fatalf("Unexpected height after rollback: got %d, want 1", height)
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("height"))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if height := binary.LittleEndian.Uint32(val); height != 1 {
+				return fmt.Errorf("unexpected stored height: got %d, want 1", height)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBalance(t, s, "3")
+	for _, q := range []*searchQuery{
+		{address: addr2slice(testAcct)},
+		{contract: addr2slice(ontAddr)},
+		{typ: opMint},
+	} {
+		q.limit = searchLimit
+		q.maxBlock = 3
+		results, total, xerr := s.searchTransactions(q)
+		if xerr != nil {
+			t.Fatalf("Failed to search transactions: %s", xerr.Message)
+		}
+		if total != 2 || len(results) != 2 || results[0].height != 1 {
+			t.Fatalf("Unexpected search results after rollback: %d total, %v", total, results)
+		}
+	}
+	offline, err := NewStore(t.TempDir(), nil, nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create offline store: %s", err)
+	}
+	defer offline.Close()
+	if err := offline.RollbackTo(0); err == nil {
+		t.Fatalf("Expected an error when rolling back in offline mode")
+	}
+}
+
+func TestSearchTransactions(t *testing.T) {
+	s := newTestStore(t)
+	for height := uint32(0); height < 3; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, 1)); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	txhash := common.Uint256{}
+	copy(txhash[:], testTxnHash(1))
+	for _, tc := range []struct {
+		name    string
+		query   *searchQuery
+		total   int64
+		heights []uint32
+	}{
+		{"all", &searchQuery{}, 3, []uint32{2, 1, 0}},
+		{"address", &searchQuery{address: addr2slice(testAcct)}, 3, []uint32{2, 1, 0}},
+		{"native account", &searchQuery{acct: addr2slice(testAcct)}, 3, []uint32{2, 1, 0}},
+		{"sub-account", &searchQuery{acct: addr2slice(testAcct), acctContract: addr2slice(ongAddr)}, 0, nil},
+		{"currency", &searchQuery{contract: addr2slice(ontAddr)}, 3, []uint32{2, 1, 0}},
+		{"type", &searchQuery{typ: opMint}, 3, []uint32{2, 1, 0}},
+		{"no type", &searchQuery{typ: opBurn}, 0, nil},
+		{"and", &searchQuery{contract: addr2slice(ontAddr), typ: opBurn}, 0, nil},
+		{"or", &searchQuery{or: true, txhash: &txhash, typ: opBurn}, 1, []uint32{1}},
+		{"txhash", &searchQuery{txhash: &txhash}, 1, []uint32{1}},
+		{"page", &searchQuery{limit: 1, maxBlock: 1, offset: 1}, 2, []uint32{0}},
+	} {
+		if tc.query.limit == 0 {
+			tc.query.limit = searchLimit
+		}
+		if tc.query.maxBlock == 0 {
+			tc.query.maxBlock = 2
+		}
+		results, total, xerr := s.searchTransactions(tc.query)
+		if xerr != nil {
+			t.Fatalf("Failed to search transactions for %s: %s", tc.name, xerr.Message)
+		}
+		if total != tc.total {
+			t.Errorf("Unexpected total for %s: got %d, want %d", tc.name, total, tc.total)
+		}
+		heights := []uint32{}
+		for _, result := range results {
+			heights = append(heights, result.height)
+		}
+		if fmt.Sprint(heights) != fmt.Sprint(append([]uint32{}, tc.heights...)) {
+			t.Errorf("Unexpected heights for %s: got %v, want %v", tc.name, heights, tc.heights)
+		}
+	}
+	if err := s.RollbackTo(0); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	_, total, xerr := s.searchTransactions(&searchQuery{
+		address:  addr2slice(testAcct),
+		limit:    searchLimit,
+		maxBlock: 2,
+	})
+	if xerr != nil {
+		t.Fatalf("Failed to search transactions: %s", xerr.Message)
+	}
+	if total != 1 {
+		t.Fatalf("Unexpected total after rollback: got %d, want 1", total)
+	}
+}
+
+func TestSetBlocks(t *testing.T) {
+	s := newTestStore(t)
+	batch := []*blockState{}
+	for height := uint32(0); height < 4; height++ {
+		batch = append(batch, testBlockState(height, height-1, int64(height+1)))
+	}
+	if err := s.setBlocks(batch); err != nil {
+		t.Fatalf("Failed to set blocks: %s", err)
+	}
+	if height := s.getHeight(); height != 3 {
+		t.Fatalf("Unexpected height after batch: got %d, want 3", height)
+	}
+	testBalance(t, s, "10")
+	err := s.setBlocks([]*blockState{
+		testBlockState(4, 3, 1),
+		testBlockState(5, 10, 1),
+	})
+	if err != errParentMismatch {
+		t.Fatalf("Expected errParentMismatch for an orphaned parent, got: %v", err)
+	}
+	if height := s.getHeight(); height != 3 {
+		t.Fatalf("Unexpected height after failed batch: got %d, want 3", height)
+	}
+	testBalance(t, s, "10")
+}
+
+func TestStakedBalance(t *testing.T) {
+	s := newTestStore(t)
+	peers := [][]byte{
+		append([]byte{2}, bytes.Repeat([]byte{0xaa}, 32)...),
+		append([]byte{3}, bytes.Repeat([]byte{0xbb}, 32)...),
+	}
+	ont := func(v int64) *big.Int {
+		return big.NewInt(v * 1000000000)
+	}
+	for height, tc := range []struct {
+		stake *stakingInvoke
+		xfer  *transfer
+	}{{
+		&stakingInvoke{acct: testAcct, amounts: []*big.Int{ont(500), ont(1000)}, peers: peers, typ: opStake},
+		&transfer{amount: ont(1500), from: testAcct, to: govAddr},
+	}, {
+		&stakingInvoke{acct: testAcct, amounts: []*big.Int{ont(200)}, peers: peers[:1], typ: opWithdraw},
+		&transfer{amount: ont(200), from: govAddr, to: testAcct},
+	}} {
+		diffs := map[common.Address]map[common.Address]*big.Int{}
+		staked := map[common.Address]map[string]*big.Int{}
+		evt := &event.NotifyEventInfo{ContractAddress: ontAddr}
+		mismatch := &transfer{amount: ont(1), from: tc.xfer.from, to: tc.xfer.to}
+		if got := stakingTransfers(tc.stake, evt, mismatch, diffs, staked); got != nil {
+			t.Fatalf("Unexpected staking transfers for a mismatched amount: %v", got)
+		}
+		xfers := stakingTransfers(tc.stake, evt, tc.xfer, diffs, staked)
+		if len(xfers) != len(tc.stake.peers) {
+			t.Fatalf("Unexpected number of %s transfers: %d", tc.stake.typ, len(xfers))
+		}
+		want := (&big.Int{}).Set(tc.xfer.amount)
+		if tc.xfer.from == govAddr {
+			want.Neg(want)
+		}
+		if got := diffs[govAddr][ontAddr]; got.Cmp(want) != 0 {
+			t.Fatalf("Unexpected ONT balance change for govAddr: got %s, want %s", got, want)
+		}
+		for _, xfer := range xfers {
+			for _, op := range transferOps(xfer) {
+				if op.typ != tc.stake.typ {
+					t.Fatalf("Unexpected op type: got %q, want %q", op.typ, tc.stake.typ)
+				}
+			}
+		}
+		state := testBlockState(uint32(height), uint32(height-1), 1)
+		henc := lexinum.EncodeHeight(uint32(height))
+		state.changes = append(state.changes, stakedChanges(staked, henc)...)
+		if err := s.setBlock(state); err != nil {
+			t.Fatalf("Failed to set block: %s", err)
+		}
+	}
+	for _, tc := range []struct {
+		height int64
+		peer   []byte
+		want   *big.Int
+	}{
+		{0, nil, ont(1500)},
+		{0, peers[0], ont(500)},
+		{1, nil, ont(1300)},
+		{1, peers[0], ont(300)},
+		{1, peers[1], ont(1000)},
+	} {
+		height := tc.height
+		resp, xerr := s.getStakedBalance(
+			&types.PartialBlockIdentifier{Index: &height}, testAcct, nil, tc.peer,
+		)
+		if xerr != nil {
+			t.Fatalf("Failed to get staked balance: %s", xerr.Message)
+		}
+		if got := resp.Balances[0].Value; got != tc.want.String() {
+			t.Fatalf(
+				"Unexpected staked balance at height %d for peer %x: got %s, want %s",
+				tc.height, tc.peer, got, tc.want,
+			)
+		}
+	}
+}
+
+func TestTokenBackfill(t *testing.T) {
+	contract := mustHexAddr("ff31ec74d01f7b7d45ed2add930f5d2239f7de33")
+	token := &OEP4Token{Contract: contract, Decimals: 9, StartHeight: 1, Symbol: "WING"}
+	s, err := NewStore(t.TempDir(), []*OEP4Token{token}, nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	defer s.Close()
+	s.offline = false
+	if err := s.initTokens(nil); err != nil {
+		t.Fatalf("Failed to initialize tokens: %s", err)
+	}
+	for height := uint32(0); height < 4; height++ {
+		if err := s.setBlock(testBlockState(height, height-1, 1)); err != nil {
+			t.Fatalf("Failed to set block at height %d: %s", height, err)
+		}
+	}
+	if err := s.initTokens([]*OEP4Token{token}); err != nil {
+		t.Fatalf("Failed to initialize tokens: %s", err)
+	}
+	if !s.isPendingToken(contract) {
+		t.Fatalf("Expected new token to be pending")
+	}
+	if _, xerr := s.getBalance(nil, testAcct, nil, contract); xerr == nil || xerr.Code != errCurrencyUnavailable.Code {
+		t.Fatalf("Expected errCurrencyUnavailable for a pending token, got: %v", xerr)
+	}
+	ont := s.tokens[ontAddr].currency
+	resp, xerr := s.getBalance(nil, testAcct, []*types.Currency{ont}, ontAddr, contract)
+	if xerr != nil {
+		t.Fatalf("Failed to get balance with the pending token filtered out: %s", xerr.Message)
+	}
+	if len(resp.Balances) != 1 || resp.Balances[0].Value != "4" {
+		t.Fatalf("Unexpected balances with the pending token filtered out: %v", resp.Balances)
+	}
+	testBackfill := func(height uint32, amount int64) *backfillState {
+		diffs := map[common.Address]map[common.Address]*big.Int{
+			testAcct: {contract: big.NewInt(amount)},
+		}
+		return &backfillState{
+			changes: balanceChanges(diffs, lexinum.EncodeHeight(height)),
+			hash:    testBlockHash(height),
+			height:  height,
+			transfers: map[int][]*model.Transfer{
+				0: {{
+					Amount:   big.NewInt(amount).Bytes(),
+					Contract: addr2slice(contract),
+					From:     addr2slice(nullAddr),
+					To:       addr2slice(testAcct),
+				}},
+			},
+		}
+	}
+	if err := s.setBackfill(contract, 0, 3, nil); err == nil {
+		t.Fatalf("Expected an error when backfilling from before the start height")
+	}
+	err = s.setBackfill(contract, 1, 3, []*backfillState{testBackfill(2, 5), testBackfill(3, 7)})
+	if err != nil {
+		t.Fatalf("Failed to backfill token: %s", err)
+	}
+	hash, _ := common.Uint256ParseFromBytes(testTxnHash(3))
+	_, txn, xerr := s.getTransaction(hash)
+	if xerr != nil {
+		t.Fatalf("Failed to get transaction: %s", xerr.Message)
+	}
+	if len(txn.Transfers) != 2 {
+		t.Fatalf("Unexpected number of transfers after backfill: got %d, want 2", len(txn.Transfers))
+	}
+	// NOTE(tav): Rolling back also removes the backfilled data, after which
+	// the token has caught up with the indexed height.
+	if err := s.RollbackTo(2); err != nil {
+		t.Fatalf("Failed to roll back: %s", err)
+	}
+	if err := s.setBackfill(contract, 4, 4, nil); err == nil {
+		t.Fatalf("Expected an error when backfilling past a rollback")
+	}
+	s.syncTokens()
+	if s.isPendingToken(contract) {
+		t.Fatalf("Expected token to be synced after catching up")
+	}
+	if err := s.setBlock(testBlockState(3, 2, 1)); err != nil {
+		t.Fatalf("Failed to re-index block at height 3: %s", err)
+	}
+	resp, xerr = s.getBalance(nil, testAcct, nil, contract)
+	if xerr != nil {
+		t.Fatalf("Failed to get balance: %s", xerr.Message)
+	}
+	if resp.Balances[0].Value != "5" {
+		t.Fatalf("Unexpected balance: got %s, want 5", resp.Balances[0].Value)
+	}
+	if err := s.initTokens(nil); err != nil {
+		t.Fatalf("Failed to initialize tokens: %s", err)
+	}
+	err = s.db.View(func(txn *badger.Txn) error {
+		rng, err := getTokenRange(txn, contract)
+		if err != nil {
+			return err
+		}
+		if rng.Synced || rng.Start != 1 || rng.Next != 4 {
+			t.Errorf("Unexpected range for removed token: %v", rng)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateBalances(t *testing.T) {
+	s := newTestStore(t)
+	peer := append([]byte{2}, bytes.Repeat([]byte{0xaa}, 32)...)
+	stake := &stakingInvoke{
+		acct:    testAcct,
+		amounts: []*big.Int{big.NewInt(3)},
+		peers:   [][]byte{peer},
+		typ:     opStake,
+	}
+	diffs := map[common.Address]map[common.Address]*big.Int{
+		govAddr:  {ontAddr: big.NewInt(5)},
+		testAcct: {ontAddr: big.NewInt(10)},
+	}
+	staked := map[common.Address]map[string]*big.Int{}
+	evt := &event.NotifyEventInfo{ContractAddress: ontAddr}
+	xfer := &transfer{amount: big.NewInt(3), from: testAcct, to: govAddr}
+	if xfers := stakingTransfers(stake, evt, xfer, diffs, staked); xfers == nil {
+		t.Fatalf("Expected staking transfers for the stake")
+	}
+	if got := diffs[govAddr][ontAddr]; got.Cmp(big.NewInt(8)) != 0 {
+		t.Fatalf("Unexpected ONT balance change for govAddr: got %s, want 8", got)
+	}
+	state := testBlockState(0, 0, 10)
+	henc := lexinum.EncodeHeight(0)
+	state.changes = append(balanceChanges(diffs, henc), stakedChanges(staked, henc)...)
+	if err := s.setBlock(state); err != nil {
+		t.Fatalf("Failed to set block: %s", err)
+	}
+	onChain := map[string]*big.Int{
+		"governance": big.NewInt(8),
+		"native":     big.NewInt(7),
+		"staking":    big.NewInt(3),
+	}
+	seen := map[string]bool{}
+	balanceOf := func(info accountInfo) (*big.Int, error) {
+		// NOTE(tav): On chain, the governance contract also holds the staked
+		// ONT, so its indexed ONT balance includes the stake.
+		if info.acct == govAddr {
+			if info.peer != nil || !info.native || info.contract != ontAddr {
+				return nil, fmt.Errorf("unexpected govAddr info: %+v", info)
+			}
+			seen["governance"] = true
+			return onChain["governance"], nil
+		}
+		if info.acct != testAcct {
+			return nil, fmt.Errorf("unexpected account %s", info.acct.ToBase58())
+		}
+		if info.peer != nil {
+			if !bytes.Equal(info.peer, peer) || info.contract != ontAddr {
+				return nil, fmt.Errorf("unexpected staking info: %+v", info)
+			}
+			seen["staking"] = true
+			return onChain["staking"], nil
+		}
+		if !info.native || info.contract != ontAddr {
+			return nil, fmt.Errorf("unexpected account info: %+v", info)
+		}
+		seen["native"] = true
+		return onChain["native"], nil
+	}
+	if err := s.validateBalances(balanceOf); err != nil {
+		t.Fatalf("Failed to validate balances: %s", err)
+	}
+	if !seen["governance"] || !seen["native"] || !seen["staking"] {
+		t.Fatalf("Expected the native and staked balances to be validated: %v", seen)
+	}
+	onChain["native"] = big.NewInt(10)
+	if err := s.validateBalances(balanceOf); err == nil {
+		t.Fatalf("Expected an error for a mismatching native balance")
+	}
+}
+
+func TestIndexBlocks(t *testing.T) {
+	s := newTestStore(t)
+	var latest uint32
+	handlers := make(chan func(v interface{}), 2)
+	origFetcher, origHeight, origSubscribe := blockFetcher, chainHeight, subscribeSaved
+	blockFetcher = func(s *Store, height uint32, synced uint32) (*blockState, error) {
+		state := testBlockState(height, height-1, 1)
+		state.synced = synced
+		return state, nil
+	}
+	chainHeight = func() uint32 {
+		return atomic.LoadUint32(&latest)
+	}
+	subscribeSaved = func(handler func(v interface{})) {
+		handlers <- handler
+	}
+	t.Cleanup(func() {
+		blockFetcher, chainHeight, subscribeSaved = origFetcher, origHeight, origSubscribe
+	})
+	run := func(wait time.Duration) (func(v interface{}), func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cfg := IndexConfig{
+			BatchSize: 10,
+			Done:      make(chan bool, 1),
+			Prefetch:  2,
+			WaitTime:  wait,
+			Workers:   2,
+		}
+		go s.IndexBlocks(ctx, cfg)
+		return <-handlers, func() {
+			cancel()
+			<-cfg.Done
+		}
+	}
+	waitForHeight := func(height uint32) {
+		deadline := time.Now().Add(5 * time.Second)
+		for s.getHeight() != height {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for height %d: got %d", height, s.getHeight())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// NOTE(tav): With a long wait time, only the notification from the ledger
+	// triggers indexing.
+	notify, stop := run(time.Hour)
+	atomic.StoreUint32(&latest, 2)
+	notify(nil)
+	waitForHeight(2)
+	stop()
+	// NOTE(tav): Without any notifications, indexing falls back to polling.
+	_, stop = run(10 * time.Millisecond)
+	atomic.StoreUint32(&latest, 5)
+	waitForHeight(5)
+	stop()
+	testBalance(t, s, "6")
+}
+
+func TestIndexRange(t *testing.T) {
+	s := newTestStore(t)
+	s.db.Close()
+	opts := badger.DefaultOptions(t.TempDir()).WithLogger(nil).WithMemTableSize(1 << 20)
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatalf("Failed to open store: %s", err)
+	}
+	s.db = db
+	maxCount := s.db.MaxBatchCount() / 2
+	maxSize := s.db.MaxBatchSize() / 2
+	// NOTE(tav): Each block has enough balance changes that only a few of them
+	// fit within a batch, and the fake fetcher returns the blocks in a shuffled
+	// order.
+	const end = 15
+	extra := int(maxCount / 3)
+	delays := rand.Perm(end + 1)
+	orig := blockFetcher
+	blockFetcher = func(s *Store, height uint32, synced uint32) (*blockState, error) {
+		time.Sleep(time.Duration(delays[height]) * time.Millisecond)
+		state := testBlockState(height, height-1, 1)
+		henc := lexinum.EncodeHeight(height)
+		for i := 0; i < extra; i++ {
+			acct := common.Address{0x22, byte(i), byte(i >> 8)}
+			prefix := accountKeyPrefix(addr2slice(acct), addr2slice(ontAddr))
+			state.changes = append(state.changes, &balanceChange{
+				diff:   big.NewInt(1),
+				key:    append(append([]byte{}, prefix...), henc...),
+				prefix: prefix,
+			})
+		}
+		state.synced = synced
+		return state, nil
+	}
+	t.Cleanup(func() {
+		blockFetcher = orig
+	})
+	cfg := IndexConfig{BatchSize: 4, Prefetch: 8, Workers: 4}
+	if !s.indexRange(context.Background(), cfg, 0, end) {
+		t.Fatalf("Unexpected cancellation of indexRange")
+	}
+	if height := s.getHeight(); height != end {
+		t.Fatalf("Unexpected height after indexing: got %d, want %d", height, end)
+	}
+	testBalance(t, s, fmt.Sprint(end+1))
+	events, _, xerr := s.getBlockEvents(new(int64), 100)
+	if xerr != nil {
+		t.Fatalf("Failed to get block events: %s", xerr.Message)
+	}
+	for i, evt := range events {
+		if evt.BlockIdentifier.Index != int64(i) {
+			t.Fatalf("Block %d was committed out of order at %d", evt.BlockIdentifier.Index, i)
+		}
+	}
+	// NOTE(tav): The blocks committed within the same transaction share the
+	// same version.
+	type batch struct {
+		blocks int
+		count  int64
+		size   int64
+	}
+	batches := map[uint64]*batch{}
+	batched := false
+	err = s.db.View(func(txn *badger.Txn) error {
+		for height := uint32(0); height <= end; height++ {
+			item, err := txn.Get(blockKey(height))
+			if err != nil {
+				return err
+			}
+			b, ok := batches[item.Version()]
+			if !ok {
+				b = &batch{}
+				batches[item.Version()] = b
+			}
+			state, _ := blockFetcher(s, height, end)
+			n, sz := state.writeSize()
+			b.blocks++
+			b.count += n
+			b.size += sz
+			if b.blocks > 1 {
+				batched = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !batched {
+		t.Fatalf("Expected some blocks to be committed together")
+	}
+	for _, b := range batches {
+		if b.blocks > cfg.BatchSize || (b.blocks > 1 && (b.count > maxCount || b.size > maxSize)) {
+			t.Fatalf(
+				"Batch of %d blocks exceeds the limits: %d entries, %d bytes",
+				b.blocks, b.count, b.size,
+			)
+		}
+	}
+}
+
+func TestNegativeBalance(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.setBlock(testBlockState(0, 0, 1)); err != nil {
+		t.Fatalf("Failed to set block: %s", err)
+	}
+	if err := s.setBlock(testBlockState(1, 0, -5)); err != nil {
+		t.Fatalf("Failed to set block with a negative balance: %s", err)
+	}
+	testBalance(t, s, "0")
+}
+
+func TestTransferOpsClaim(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		contract []byte
+		isGas    bool
+		want     string
+	}{
+		{"claim", addr2slice(ongAddr), false, opClaimONG},
+		{"gas", addr2slice(ongAddr), true, opGasFee},
+		{"ont", addr2slice(ontAddr), false, opTransferFrom},
+	} {
+		ops := transferOps(&model.Transfer{
+			Contract: tc.contract,
+			From:     addr2slice(ontAddr),
+			IsGas:    tc.isGas,
+			Spender:  addr2slice(testAcct),
+			To:       addr2slice(testAcct),
+		})
+		if len(ops) != 2 {
+			t.Fatalf("Unexpected number of ops for %s: %d", tc.name, len(ops))
+		}
+		for _, op := range ops {
+			if op.typ != tc.want {
+				t.Fatalf("Unexpected op type for %s: got %q, want %q", tc.name, op.typ, tc.want)
+			}
+		}
+	}
+}
+
+func TestTransferSpender(t *testing.T) {
+	spender := mustHexAddr("1200000000000000000000000000000000000000")
+	code, err := utils.BuildNativeInvokeCode(ontAddr, 0, "transferFromV2", []interface{}{&chain.Transfer{
+		Payer:  spender,
+		From:   testAcct,
+		To:     govAddr,
+		Amount: big.NewInt(10),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mut := &ctypes.MutableTransaction{
+		Payload: &payload.InvokeCode{Code: code},
+		Sigs:    []ctypes.Sig{},
+		TxType:  ctypes.InvokeNeo,
+	}
+	txn, err := mut.IntoImmutable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := parseInvoke(txn)
+	if inv == nil {
+		t.Fatal("Failed to parse the transferFromV2 invoke")
+	}
+	xfer := &transfer{amount: big.NewInt(10), from: testAcct, to: govAddr}
+	got := transferSpender(inv, ontAddr, xfer)
+	if !bytes.Equal(got, addr2slice(spender)) {
+		t.Fatalf("Unexpected spender: got %x, want %x", got, addr2slice(spender))
+	}
+	if got := transferSpender(inv, ongAddr, xfer); got != nil {
+		t.Fatalf("Unexpected spender for a different contract: %x", got)
+	}
+	ops := transferOps(&model.Transfer{
+		From:    addr2slice(testAcct),
+		Spender: got,
+		To:      addr2slice(govAddr),
+	})
+	for _, op := range ops {
+		if op.typ != opTransferFrom {
+			t.Fatalf("Unexpected op type for transfer: got %q, want %q", op.typ, opTransferFrom)
+		}
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	s, err := NewStore(t.TempDir(), nil, nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	// NOTE(tav): The store is opened without a node, but blocks are indexed
+	// into it directly, so it isn't treated as being in offline mode.
+	s.offline = false
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s
+}
+
+func testBalance(t *testing.T, s *Store, expected string) {
+	resp, xerr := s.getBalance(nil, testAcct, nil, ontAddr)
+	if xerr != nil {
+		t.Fatalf("Failed to get balance: %s", xerr.Message)
+	}
+	if resp.Balances[0].Value != expected {
+		t.Fatalf("Unexpected balance: got %s, want %s", resp.Balances[0].Value, expected)
+	}
+}
+
+func testBlockState(height uint32, parent uint32, amount int64) *blockState {
+	henc := lexinum.EncodeHeight(height)
+	prefix := accountKeyPrefix(addr2slice(testAcct), addr2slice(ontAddr))
+	key := make([]byte, len(prefix)+len(henc))
+	n := copy(key, prefix)
+	copy(key[n:], henc)
+	hash := testTxnHash(height)
+	return &blockState{
+		block: &model.Block{
+			Transactions: []*model.Transaction{{
+				Hash: hash,
+				Transfers: []*model.Transfer{{
+					Amount:   big.NewInt(amount).Bytes(),
+					Contract: addr2slice(ontAddr),
+					From:     addr2slice(nullAddr),
+					To:       addr2slice(testAcct),
+				}},
+			}},
+		},
+		changes: []*balanceChange{{
+			diff:   big.NewInt(amount),
+			key:    key,
+			prefix: prefix,
+		}},
+		hashes: [][]byte{hash},
+		id: &blockID{
+			hash:   testBlockHash(height),
+			height: height,
+		},
+		parent: testBlockHash(parent),
+	}
+}
+
+func testBlockHash(height uint32) common.Uint256 {
+	hash := common.Uint256{}
+	hash[0] = byte(height + 1)
+	return hash
+}
+
+func testTxnHash(height uint32) []byte {
+	hash := testBlockHash(height)
+	hash[1] = 0xff
+	return hash[:]
+}
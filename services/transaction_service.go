@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ontio/ontology-rosetta/log"
+	"github.com/ontio/ontology/common"
+)
+
+// TransactionRequest is the request body for the /transaction endpoint, which
+// looks up an indexed transaction by its hash alone.
+type TransactionRequest struct {
+	NetworkIdentifier     *types.NetworkIdentifier     `json:"network_identifier"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+}
+
+// TransactionResponse is the response body for the /transaction endpoint.
+type TransactionResponse struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Transaction     *types.Transaction     `json:"transaction"`
+}
+
+type transactionController struct {
+	asserter *asserter.Asserter
+	svc      *service
+}
+
+func (c *transactionController) Routes() server.Routes {
+	return server.Routes{{
+		Name:        "Transaction",
+		Method:      http.MethodPost,
+		Pattern:     "/transaction",
+		HandlerFunc: c.transaction,
+	}}
+}
+
+func (c *transactionController) transaction(w http.ResponseWriter, r *http.Request) {
+	req := &TransactionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	if err := c.asserter.ValidSupportedNetwork(req.NetworkIdentifier); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	if err := asserter.TransactionIdentifier(req.TransactionIdentifier); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	resp, xerr := c.svc.Transaction(r.Context(), req)
+	if xerr != nil {
+		server.EncodeJSONResponse(xerr, http.StatusInternalServerError, w)
+		return
+	}
+	server.EncodeJSONResponse(resp, http.StatusOK, w)
+}
+
+// Transaction implements the /transaction endpoint.
+func (s *service) Transaction(ctx context.Context, r *TransactionRequest) (*TransactionResponse, *types.Error) {
+	if s.offline {
+		return nil, errOfflineMode
+	}
+	txhash, err := common.Uint256FromHexString(r.TransactionIdentifier.Hash)
+	if err != nil {
+		return nil, errInvalidTransactionHash
+	}
+	info, src, xerr := s.store.getTransaction(txhash)
+	if xerr != nil {
+		return nil, xerr
+	}
+	dst, xerr, err := s.transformTransaction(src)
+	if xerr != nil {
+		return nil, xerr
+	}
+	if err != nil {
+		log.Errorf(
+			"Consistency failure when decoding transaction hash %q at block %d: %s",
+			r.TransactionIdentifier.Hash, info.height, err,
+		)
+		return nil, wrapErr(errDatastoreConsistency, err)
+	}
+	return &TransactionResponse{
+		BlockIdentifier: info.blockID,
+		Transaction:     dst,
+	}, nil
+}